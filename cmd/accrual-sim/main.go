@@ -0,0 +1,91 @@
+// Command accrual-sim is an in-memory stand-in for the accrual system, so
+// gophermart can be run and tested offline.
+//
+// Orders are registered with POST /api/orders and reward rules with
+// POST /api/goods, using the same payloads as the real accrual system.
+// GET /api/orders/{number} answers as described in SPECIFICATION.md.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/caarlos0/env/v6"
+
+	"github.com/nmramorov/gophemart/internal/accrualsim"
+	"github.com/nmramorov/gophemart/internal/logger"
+)
+
+type Config struct {
+	Address         string        `env:"RUN_ADDRESS" envDefault:"localhost:8081"`
+	RegisteredDelay time.Duration `env:"REGISTERED_DELAY" envDefault:"1s"`
+	ProcessingDelay time.Duration `env:"PROCESSING_DELAY" envDefault:"2s"`
+	RateLimit       int           `env:"RATE_LIMIT" envDefault:"0"`
+	GoodsFile       string        `env:"GOODS_FILE"`
+}
+
+func newConfig() (*Config, error) {
+	config := &Config{}
+	if err := env.Parse(config); err != nil {
+		return nil, err
+	}
+	flag.StringVar(&config.Address, "a", config.Address, "server address")
+	flag.DurationVar(&config.RegisteredDelay, "registered", config.RegisteredDelay, "how long orders stay REGISTERED")
+	flag.DurationVar(&config.ProcessingDelay, "processing", config.ProcessingDelay, "how long orders stay PROCESSING")
+	flag.IntVar(&config.RateLimit, "rate", config.RateLimit, "requests per minute before answering 429, 0 for no limit")
+	flag.StringVar(&config.GoodsFile, "goods", config.GoodsFile, "JSON file with reward rules to load on start")
+	flag.Parse()
+	return config, nil
+}
+
+func loadRules(simulator *accrualsim.Simulator, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	rules := []*accrualsim.Rule{}
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return err
+	}
+	if !simulator.AddRules(rules...) {
+		return fmt.Errorf("%s holds an invalid or conflicting reward rule", path)
+	}
+	logger.InfoLog.Printf("Loaded %d reward rules from %s", len(rules), path)
+	return nil
+}
+
+func main() {
+	config, err := newConfig()
+	if err != nil {
+		logger.ErrorLog.Fatal(err)
+	}
+	simulator := accrualsim.NewSimulator(config.RegisteredDelay, config.ProcessingDelay, config.RateLimit)
+	if config.GoodsFile != "" {
+		if err := loadRules(simulator, config.GoodsFile); err != nil {
+			logger.ErrorLog.Fatal(err)
+		}
+	}
+	server := &http.Server{
+		Addr:    config.Address,
+		Handler: simulator,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdown)
+	}()
+	logger.InfoLog.Printf("Accrual simulator is running on addr %s", config.Address)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logger.ErrorLog.Fatalf("Server error: %e", err)
+	}
+}
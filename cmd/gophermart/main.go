@@ -1,8 +1,14 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"os"
+
 	"github.com/nmramorov/gophemart/internal/app"
 	config "github.com/nmramorov/gophemart/internal/configuration"
+	"github.com/nmramorov/gophemart/internal/db"
+	"github.com/nmramorov/gophemart/internal/deadletters"
 	"github.com/nmramorov/gophemart/internal/logger"
 )
 
@@ -12,6 +18,18 @@ func main() {
 	if err != nil {
 		logger.ErrorLog.Fatal(err)
 	}
+	if args := flag.Args(); len(args) > 0 && args[0] == "deadletters" {
+		cursor, err := db.GetCursor(config.NewConfig(flags, envs))
+		if err != nil {
+			logger.ErrorLog.Fatal(err)
+		}
+		err = deadletters.RunCLI(context.Background(), cursor, args[1:], os.Stdout)
+		cursor.Close()
+		if err != nil {
+			logger.ErrorLog.Fatal(err)
+		}
+		return
+	}
 	app, _err := app.NewApp(config.NewConfig(flags, envs))
 	if _err != nil {
 		logger.ErrorLog.Fatal(_err)
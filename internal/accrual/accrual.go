@@ -0,0 +1,106 @@
+package accrual
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/nmramorov/gophemart/internal/errors"
+	"github.com/nmramorov/gophemart/internal/logger"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+// Status is what the accrual system knows about an order.
+type Status string
+
+const (
+	StatusRegistered Status = "REGISTERED"
+	StatusProcessing Status = "PROCESSING"
+	StatusInvalid    Status = "INVALID"
+	StatusProcessed  Status = "PROCESSED"
+	StatusThrottled  Status = "THROTTLED"
+	StatusNotFound   Status = "NOT_FOUND"
+)
+
+// Result is a typed answer of the accrual system. RetryAfter and RateLimit
+// are only set for StatusThrottled.
+type Result struct {
+	Order      string
+	Status     Status
+	Accrual    float64
+	RetryAfter time.Duration
+	RateLimit  int
+}
+
+// AccrualClient asks the accrual system about a single order.
+type AccrualClient interface {
+	Check(ctx context.Context, number string) (*Result, error)
+}
+
+// Final reports whether the order will not change any more.
+func (r *Result) Final() bool {
+	return r.Status == StatusInvalid || r.Status == StatusProcessed
+}
+
+// StatusCode is the HTTP status the accrual system answers with for the
+// result.
+func (r *Result) StatusCode() int {
+	switch r.Status {
+	case StatusThrottled:
+		return http.StatusTooManyRequests
+	case StatusNotFound:
+		return http.StatusNoContent
+	default:
+		return http.StatusOK
+	}
+}
+
+// Response converts the result to the order update stored in the database.
+// Orders unknown to the accrual system stay NEW.
+func (r *Result) Response() *models.AccrualResponse {
+	status := string(r.Status)
+	if r.Status == StatusNotFound {
+		status = "NEW"
+	}
+	return &models.AccrualResponse{Order: r.Order, Status: status, Accrual: r.Accrual}
+}
+
+// OrderStatus maps the result to the status of the order. Orders unknown to
+// the accrual system stay NEW, statuses gophermart does not know are
+// rejected with errors.ErrUnknownStatus.
+func (r *Result) OrderStatus() (models.OrderStatus, error) {
+	if r.Status == StatusNotFound {
+		return models.OrderNew, nil
+	}
+	return models.ParseAccrualStatus(string(r.Status))
+}
+
+// FromResponse checks an accrual answer received as JSON, e.g. pushed by
+// the accrual system, and converts it to a result.
+func FromResponse(response *models.AccrualResponse) (*Result, error) {
+	if _, err := models.ParseAccrualStatus(response.Status); err != nil {
+		logger.ErrorLog.Printf("Rejecting accrual response for order %s: %e", response.Order, err)
+		return nil, errors.ErrAccrualResponse
+	}
+	if response.Order == "" || response.Accrual < 0 {
+		return nil, errors.ErrAccrualResponse
+	}
+	return &Result{Order: response.Order, Status: Status(response.Status), Accrual: response.Accrual}, nil
+}
+
+// ResponseError keeps the accrual answer which could not be used, so that
+// it ends up in the attempt history and the dead-letter queue.
+type ResponseError struct {
+	StatusCode int
+	Body       string
+	Err        error
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("accrual answered %d: %s", e.StatusCode, e.Err)
+}
+
+func (e *ResponseError) Unwrap() error {
+	return e.Err
+}
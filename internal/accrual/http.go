@@ -0,0 +1,117 @@
+package accrual
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/nmramorov/gophemart/internal/errors"
+	"github.com/nmramorov/gophemart/internal/logger"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+// DEFAULTRETRYAFTER is used when a 429 response has no usable Retry-After.
+const DEFAULTRETRYAFTER = 1
+
+var limitPattern = regexp.MustCompile(`No more than (\d+) requests per minute allowed`)
+
+// HTTPClient talks to the accrual system over its REST API.
+type HTTPClient struct {
+	client  *resty.Client
+	Timeout time.Duration
+}
+
+func NewHTTPClient(baseURL string, timeout time.Duration) *HTTPClient {
+	return &HTTPClient{
+		client:  resty.New().SetBaseURL(baseURL),
+		Timeout: timeout,
+	}
+}
+
+// SetAuth sends a bearer token, or basic auth credentials when no token is
+// given, with every request.
+func (c *HTTPClient) SetAuth(token, username, password string) *HTTPClient {
+	switch {
+	case token != "":
+		c.client.SetAuthToken(token)
+	case username != "":
+		c.client.SetBasicAuth(username, password)
+	}
+	return c
+}
+
+// Check requests the order status. The request is cancelled together with
+// ctx and after Timeout at the latest.
+func (c *HTTPClient) Check(ctx context.Context, number string) (*Result, error) {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	acc := models.AccrualResponse{}
+	resp, err := c.client.R().
+		SetContext(ctx).
+		SetResult(&acc).
+		SetPathParam("number", number).
+		Get("/api/orders/{number}")
+	if err != nil {
+		logger.ErrorLog.Printf("Error getting order from accrual: %e", err)
+		if resp != nil && resp.RawResponse != nil {
+			return nil, &ResponseError{StatusCode: resp.StatusCode(), Body: resp.String(), Err: err}
+		}
+		return nil, err
+	}
+	logger.InfoLog.Printf("Accrual GET status code: %d", resp.StatusCode())
+	switch resp.StatusCode() {
+	case http.StatusTooManyRequests:
+		return &Result{
+			Order:      number,
+			Status:     StatusThrottled,
+			RetryAfter: ParseRetryAfter(resp.Header().Get("Retry-After")),
+			RateLimit:  ParseRateLimit(resp.String()),
+		}, nil
+	case http.StatusNoContent:
+		return &Result{Order: number, Status: StatusNotFound}, nil
+	case http.StatusOK:
+		if result, err := FromResponse(&acc); err == nil {
+			return result, nil
+		}
+		logger.ErrorLog.Printf("Malformed accrual response for order %s: %s", number, resp.String())
+		return nil, &ResponseError{StatusCode: resp.StatusCode(), Body: resp.String(), Err: errors.ErrAccrualResponse}
+	}
+	logger.ErrorLog.Printf("Accrual answered %d for order %s: %s", resp.StatusCode(), number, resp.String())
+	return nil, &ResponseError{StatusCode: resp.StatusCode(), Body: resp.String(), Err: errors.ErrAccrualResponse}
+}
+
+// ParseRetryAfter reads a Retry-After header given either in seconds or as
+// an HTTP date.
+func ParseRetryAfter(header string) time.Duration {
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		if wait := time.Until(at); wait > 0 {
+			return wait
+		}
+		return 0
+	}
+	return DEFAULTRETRYAFTER * time.Second
+}
+
+// ParseRateLimit reads N from the "No more than N requests per minute
+// allowed" body of a 429 response, zero if it is missing.
+func ParseRateLimit(body string) int {
+	match := limitPattern.FindStringSubmatch(body)
+	if match == nil {
+		return 0
+	}
+	limit, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0
+	}
+	return limit
+}
@@ -0,0 +1,89 @@
+package accrual
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nmramorov/gophemart/internal/errors"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	assert.Equal(t, 60*time.Second, ParseRetryAfter("60"))
+	assert.Equal(t, DEFAULTRETRYAFTER*time.Second, ParseRetryAfter(""))
+	assert.Equal(t, time.Duration(0), ParseRetryAfter(time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)))
+	wait := ParseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	assert.Greater(t, wait, 59*time.Minute)
+}
+
+func TestParseRateLimit(t *testing.T) {
+	assert.Equal(t, 120, ParseRateLimit("No more than 120 requests per minute allowed\n"))
+	assert.Equal(t, 0, ParseRateLimit("Too Many Requests"))
+}
+
+func TestHTTPClientCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/orders/1":
+			rw.Header().Set("Content-Type", "application/json")
+			rw.Write([]byte(`{"order": "1", "status": "PROCESSED", "accrual": 500}`))
+		case "/api/orders/2":
+			rw.Header().Set("Content-Type", "application/json")
+			rw.Write([]byte(`{"order": "2", "status": "REGISTERED"}`))
+		case "/api/orders/3":
+			rw.WriteHeader(http.StatusNoContent)
+		case "/api/orders/4":
+			rw.Header().Set("Retry-After", "2")
+			rw.WriteHeader(http.StatusTooManyRequests)
+			rw.Write([]byte("No more than 30 requests per minute allowed"))
+		case "/api/orders/5":
+			rw.Header().Set("Content-Type", "application/json")
+			rw.Write([]byte(`{"order": "5", "status": "LOST"}`))
+		case "/api/orders/6":
+			time.Sleep(100 * time.Millisecond)
+		default:
+			rw.WriteHeader(http.StatusInternalServerError)
+			rw.Write([]byte("oops"))
+		}
+	}))
+	defer server.Close()
+	client := NewHTTPClient(server.URL, 50*time.Millisecond)
+
+	tests := []struct {
+		number string
+		want   *Result
+	}{
+		{number: "1", want: &Result{Order: "1", Status: StatusProcessed, Accrual: 500}},
+		{number: "2", want: &Result{Order: "2", Status: StatusRegistered}},
+		{number: "3", want: &Result{Order: "3", Status: StatusNotFound}},
+		{number: "4", want: &Result{Order: "4", Status: StatusThrottled, RetryAfter: 2 * time.Second, RateLimit: 30}},
+	}
+	for _, tt := range tests {
+		result, err := client.Check(context.Background(), tt.number)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, result)
+	}
+
+	_, err := client.Check(context.Background(), "5")
+	assert.ErrorIs(t, err, errors.ErrAccrualResponse)
+
+	_, err = client.Check(context.Background(), "7")
+	responseErr, ok := err.(*ResponseError)
+	assert.True(t, ok)
+	assert.Equal(t, 500, responseErr.StatusCode)
+	assert.Equal(t, "oops", responseErr.Body)
+
+	_, err = client.Check(context.Background(), "6")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestResultResponse(t *testing.T) {
+	assert.Equal(t, "NEW", (&Result{Order: "1", Status: StatusNotFound}).Response().Status)
+	assert.Equal(t, "PROCESSED", (&Result{Order: "1", Status: StatusProcessed}).Response().Status)
+	assert.True(t, (&Result{Status: StatusInvalid}).Final())
+	assert.False(t, (&Result{Status: StatusRegistered}).Final())
+}
@@ -0,0 +1,101 @@
+package accrual
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// Interaction is one recorded answer of the accrual system as stored in a
+// fixture file.
+type Interaction struct {
+	Status     Status  `json:"status,omitempty"`
+	Accrual    float64 `json:"accrual,omitempty"`
+	RetryAfter float64 `json:"retry_after,omitempty"`
+	RateLimit  int     `json:"rate_limit,omitempty"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// Fixtures maps order numbers to the answers recorded for them, in order.
+type Fixtures map[string][]*Interaction
+
+func newInteraction(result *Result, err error) *Interaction {
+	if err != nil {
+		return &Interaction{Error: err.Error()}
+	}
+	return &Interaction{
+		Status:     result.Status,
+		Accrual:    result.Accrual,
+		RetryAfter: result.RetryAfter.Seconds(),
+		RateLimit:  result.RateLimit,
+	}
+}
+
+func (i *Interaction) step() Step {
+	if i.Error != "" {
+		return Step{Err: errors.New(i.Error)}
+	}
+	return Step{Result: &Result{
+		Status:     i.Status,
+		Accrual:    i.Accrual,
+		RetryAfter: time.Duration(i.RetryAfter * float64(time.Second)),
+		RateLimit:  i.RateLimit,
+	}}
+}
+
+// Recorder passes checks through to Client and remembers every answer, so
+// a session against a real accrual system can be saved as fixtures.
+type Recorder struct {
+	Client   AccrualClient
+	mu       sync.Mutex
+	fixtures Fixtures
+}
+
+func NewRecorder(client AccrualClient) *Recorder {
+	return &Recorder{
+		Client:   client,
+		fixtures: make(Fixtures),
+	}
+}
+
+func (r *Recorder) Check(ctx context.Context, number string) (*Result, error) {
+	result, err := r.Client.Check(ctx, number)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fixtures[number] = append(r.fixtures[number], newInteraction(result, err))
+	return result, err
+}
+
+// Save writes the recorded answers to a JSON fixture file.
+func (r *Recorder) Save(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data, err := json.MarshalIndent(r.fixtures, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// NewReplay builds a client answering with the interactions from a JSON
+// fixture file.
+func NewReplay(path string) (*Scripted, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	fixtures := Fixtures{}
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		return nil, err
+	}
+	replay := NewScripted()
+	for number, interactions := range fixtures {
+		for _, interaction := range interactions {
+			replay.Script(number, interaction.step())
+		}
+	}
+	return replay, nil
+}
@@ -0,0 +1,69 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScripted(t *testing.T) {
+	client := NewScripted().Script("1",
+		Step{Result: &Result{Status: StatusRegistered}},
+		Step{Err: errors.New("connection reset")},
+		Step{Result: &Result{Status: StatusProcessed, Accrual: 100}},
+	)
+	ctx := context.Background()
+
+	result, err := client.Check(ctx, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, &Result{Order: "1", Status: StatusRegistered}, result)
+	_, err = client.Check(ctx, "1")
+	assert.EqualError(t, err, "connection reset")
+	for i := 0; i < 2; i++ {
+		result, _ = client.Check(ctx, "1")
+		assert.Equal(t, StatusProcessed, result.Status)
+	}
+	assert.Equal(t, 4, client.Calls("1"))
+
+	result, _ = client.Check(ctx, "2")
+	assert.Equal(t, StatusNotFound, result.Status)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = client.Check(cancelled, "1")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestRecordReplay(t *testing.T) {
+	recorder := NewRecorder(NewScripted().Script("1",
+		Step{Result: &Result{Status: StatusThrottled, RetryAfter: 1500 * time.Millisecond, RateLimit: 60}},
+		Step{Err: errors.New("connection reset")},
+		Step{Result: &Result{Status: StatusProcessed, Accrual: 729.98}},
+	))
+	ctx := context.Background()
+	recorded := []*Result{}
+	for i := 0; i < 3; i++ {
+		result, _ := recorder.Check(ctx, "1")
+		recorded = append(recorded, result)
+	}
+	path := filepath.Join(t.TempDir(), "accrual.json")
+	assert.NoError(t, recorder.Save(path))
+
+	replay, err := NewReplay(path)
+	assert.NoError(t, err)
+	result, err := replay.Check(ctx, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, recorded[0], result)
+	_, err = replay.Check(ctx, "1")
+	assert.EqualError(t, err, "connection reset")
+	result, _ = replay.Check(ctx, "1")
+	assert.Equal(t, recorded[2], result)
+
+	_, err = NewReplay(filepath.Join(t.TempDir(), "missing.json"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,69 @@
+package accrual
+
+import (
+	"context"
+	"sync"
+)
+
+// Step is one scripted answer: either a result or an error.
+type Step struct {
+	Result *Result
+	Err    error
+}
+
+// Scripted is an in-memory AccrualClient for tests. Every order answers
+// with its steps in turn and keeps repeating the last one; orders without
+// a script are not found.
+type Scripted struct {
+	mu      sync.Mutex
+	scripts map[string][]Step
+	calls   map[string]int
+}
+
+func NewScripted() *Scripted {
+	return &Scripted{
+		scripts: make(map[string][]Step),
+		calls:   make(map[string]int),
+	}
+}
+
+// Script appends steps to the answers for the order.
+func (s *Scripted) Script(number string, steps ...Step) *Scripted {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scripts[number] = append(s.scripts[number], steps...)
+	return s
+}
+
+// Calls returns how many times the order was checked.
+func (s *Scripted) Calls(number string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[number]
+}
+
+func (s *Scripted) Check(ctx context.Context, number string) (*Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	call := s.calls[number]
+	s.calls[number]++
+	steps := s.scripts[number]
+	if len(steps) == 0 {
+		return &Result{Order: number, Status: StatusNotFound}, nil
+	}
+	if call >= len(steps) {
+		call = len(steps) - 1
+	}
+	step := steps[call]
+	if step.Err != nil {
+		return nil, step.Err
+	}
+	result := *step.Result
+	if result.Order == "" {
+		result.Order = number
+	}
+	return &result, nil
+}
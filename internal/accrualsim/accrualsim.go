@@ -0,0 +1,259 @@
+package accrualsim
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/nmramorov/gophemart/internal/logger"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+const (
+	RewardPercent = "%"
+	RewardPoints  = "pt"
+)
+
+type Good struct {
+	Description string  `json:"description"`
+	Price       float64 `json:"price"`
+}
+
+// Order is an order registered in the simulator.
+type Order struct {
+	Number       string    `json:"order"`
+	Goods        []*Good   `json:"goods"`
+	RegisteredAt time.Time `json:"-"`
+}
+
+// Rule rewards every good whose description contains Match, either with a
+// percentage of its price or with a fixed number of points.
+type Rule struct {
+	Match      string  `json:"match"`
+	Reward     float64 `json:"reward"`
+	RewardType string  `json:"reward_type"`
+}
+
+func (r *Rule) Valid() bool {
+	return r.Match != "" && r.Reward > 0 && (r.RewardType == RewardPercent || r.RewardType == RewardPoints)
+}
+
+// Simulator is an in-memory accrual system. A registered order is
+// REGISTERED for RegisteredDelay, then PROCESSING for ProcessingDelay and
+// finally PROCESSED, or INVALID when its number fails the Luhn check.
+type Simulator struct {
+	*chi.Mux
+	RegisteredDelay time.Duration
+	ProcessingDelay time.Duration
+	RateLimit       int
+	mu              sync.Mutex
+	orders          map[string]*Order
+	rules           []*Rule
+	window          time.Time
+	requests        int
+	now             func() time.Time
+}
+
+func NewSimulator(registeredDelay time.Duration, processingDelay time.Duration, rateLimit int) *Simulator {
+	s := &Simulator{
+		Mux:             chi.NewMux(),
+		RegisteredDelay: registeredDelay,
+		ProcessingDelay: processingDelay,
+		RateLimit:       rateLimit,
+		orders:          make(map[string]*Order),
+		now:             time.Now,
+	}
+	s.Get("/api/orders/{number}", s.GetOrder)
+	s.Post("/api/orders", s.RegisterOrder)
+	s.Post("/api/goods", s.AddRule)
+	return s
+}
+
+// Register adds an order, false if it is already known.
+func (s *Simulator) Register(order *Order) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.orders[order.Number]; ok {
+		return false
+	}
+	order.RegisteredAt = s.now()
+	s.orders[order.Number] = order
+	return true
+}
+
+// AddRules adds reward rules, false if one of them is not valid or matches
+// the same goods as an existing rule or another rule of the batch. Nothing
+// is added then.
+func (s *Simulator) AddRules(rules ...*Rule) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, rule := range rules {
+		if !rule.Valid() {
+			return false
+		}
+		if matched(s.rules, rule) || matched(rules[:i], rule) {
+			return false
+		}
+	}
+	s.rules = append(s.rules, rules...)
+	return true
+}
+
+// matched reports whether one of rules matches the same goods as rule.
+func matched(rules []*Rule, rule *Rule) bool {
+	for _, existing := range rules {
+		if strings.EqualFold(existing.Match, rule.Match) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Simulator) accrual(order *Order) float64 {
+	var sum float64
+	for _, good := range order.Goods {
+		for _, rule := range s.rules {
+			if !strings.Contains(strings.ToLower(good.Description), strings.ToLower(rule.Match)) {
+				continue
+			}
+			if rule.RewardType == RewardPercent {
+				sum += good.Price * rule.Reward / 100
+			} else {
+				sum += rule.Reward
+			}
+		}
+	}
+	return math.Round(sum*100) / 100
+}
+
+// Status returns the accrual of the order at the current time, nil for
+// unknown orders.
+func (s *Simulator) Status(number string) *models.AccrualResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	order, ok := s.orders[number]
+	if !ok {
+		return nil
+	}
+	elapsed := s.now().Sub(order.RegisteredAt)
+	switch {
+	case elapsed < s.RegisteredDelay:
+		return &models.AccrualResponse{Order: number, Status: "REGISTERED"}
+	case elapsed < s.RegisteredDelay+s.ProcessingDelay:
+		return &models.AccrualResponse{Order: number, Status: "PROCESSING"}
+	case !luhnValid(number):
+		return &models.AccrualResponse{Order: number, Status: "INVALID"}
+	}
+	return &models.AccrualResponse{Order: number, Status: "PROCESSED", Accrual: s.accrual(order)}
+}
+
+// allow counts the request against the per-minute limit and returns how
+// long the caller has to wait when it is exceeded.
+func (s *Simulator) allow() (bool, time.Duration) {
+	if s.RateLimit <= 0 {
+		return true, 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if now.Sub(s.window) >= time.Minute {
+		s.window = now
+		s.requests = 0
+	}
+	if s.requests >= s.RateLimit {
+		return false, s.window.Add(time.Minute).Sub(now)
+	}
+	s.requests++
+	return true, 0
+}
+
+func (s *Simulator) GetOrder(rw http.ResponseWriter, r *http.Request) {
+	if ok, wait := s.allow(); !ok {
+		seconds := int(math.Ceil(wait.Seconds()))
+		rw.Header().Set("Content-Type", "text/plain")
+		rw.Header().Set("Retry-After", strconv.Itoa(seconds))
+		rw.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprintf(rw, "No more than %d requests per minute allowed", s.RateLimit)
+		return
+	}
+	response := s.Status(chi.URLParam(r, "number"))
+	if response == nil {
+		rw.WriteHeader(http.StatusNoContent)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(response)
+}
+
+func (s *Simulator) RegisterOrder(rw http.ResponseWriter, r *http.Request) {
+	order := &Order{}
+	if err := json.NewDecoder(r.Body).Decode(order); err != nil || order.Number == "" {
+		http.Error(rw, "wrong order format", http.StatusBadRequest)
+		return
+	}
+	if !digits(order.Number) {
+		http.Error(rw, "wrong order number", http.StatusBadRequest)
+		return
+	}
+	if !s.Register(order) {
+		http.Error(rw, "order already registered", http.StatusConflict)
+		return
+	}
+	logger.InfoLog.Printf("Registered order %s with %d goods", order.Number, len(order.Goods))
+	rw.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Simulator) AddRule(rw http.ResponseWriter, r *http.Request) {
+	rule := &Rule{}
+	if err := json.NewDecoder(r.Body).Decode(rule); err != nil || !rule.Valid() {
+		http.Error(rw, "wrong reward format", http.StatusBadRequest)
+		return
+	}
+	if !s.AddRules(rule) {
+		http.Error(rw, "reward already registered", http.StatusConflict)
+		return
+	}
+	logger.InfoLog.Printf("Added reward %v%s for %s", rule.Reward, rule.RewardType, rule.Match)
+	rw.WriteHeader(http.StatusOK)
+}
+
+// digits reports whether number is a non-empty string of digits. Order
+// numbers may be longer than an int64.
+func digits(number string) bool {
+	if number == "" {
+		return false
+	}
+	for _, c := range number {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// luhnValid runs the Luhn check digit by digit, so numbers of any length
+// are checked.
+func luhnValid(number string) bool {
+	if !digits(number) {
+		return false
+	}
+	sum := 0
+	for i := len(number) - 1; i >= 0; i-- {
+		digit := int(number[i] - '0')
+		if (len(number)-i)%2 == 0 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,110 @@
+package accrualsim
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nmramorov/gophemart/internal/accrual"
+)
+
+func post(t *testing.T, handler http.Handler, url string, payload interface{}) int {
+	buff := bytes.NewBuffer([]byte{})
+	json.NewEncoder(buff).Encode(payload)
+	request := httptest.NewRequest(http.MethodPost, url, buff)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, request)
+	res := w.Result()
+	defer res.Body.Close()
+	return res.StatusCode
+}
+
+func TestSimulatorProgression(t *testing.T) {
+	now := time.Now()
+	simulator := NewSimulator(time.Second, 2*time.Second, 0)
+	simulator.now = func() time.Time { return now }
+	ts := httptest.NewServer(simulator)
+	defer ts.Close()
+	client := accrual.NewHTTPClient(ts.URL, time.Second)
+
+	assert.Equal(t, 200, post(t, simulator, "/api/goods", &Rule{Match: "Bork", Reward: 10, RewardType: RewardPercent}))
+	assert.Equal(t, 200, post(t, simulator, "/api/goods", &Rule{Match: "Samsung", Reward: 15, RewardType: RewardPoints}))
+	assert.Equal(t, 409, post(t, simulator, "/api/goods", &Rule{Match: "bork", Reward: 5, RewardType: RewardPercent}))
+	assert.Equal(t, 400, post(t, simulator, "/api/goods", &Rule{Match: "LG", Reward: 5, RewardType: "other"}))
+
+	order := &Order{Number: "12345678903", Goods: []*Good{
+		{Description: "Чайник Bork", Price: 7000},
+		{Description: "Телевизор Samsung", Price: 50000},
+		{Description: "Утюг Philips", Price: 3000},
+	}}
+	assert.Equal(t, 202, post(t, simulator, "/api/orders", order))
+	assert.Equal(t, 409, post(t, simulator, "/api/orders", order))
+	assert.Equal(t, 400, post(t, simulator, "/api/orders", &Order{Number: "abc"}))
+	assert.Equal(t, 202, post(t, simulator, "/api/orders", &Order{Number: "12345678900"}))
+	assert.Equal(t, 202, post(t, simulator, "/api/orders", &Order{Number: "12345678901234567890121"}))
+	assert.Equal(t, 202, post(t, simulator, "/api/orders", &Order{Number: "12345678901234567890122"}))
+	assert.Equal(t, 400, post(t, simulator, "/api/orders", &Order{Number: "-12345678903"}))
+
+	ctx := context.Background()
+	check := func(number string) *accrual.Result {
+		result, err := client.Check(ctx, number)
+		assert.NoError(t, err)
+		return result
+	}
+	assert.Equal(t, accrual.StatusNotFound, check("79927398713").Status)
+	assert.Equal(t, accrual.StatusRegistered, check("12345678903").Status)
+
+	now = now.Add(1500 * time.Millisecond)
+	assert.Equal(t, accrual.StatusProcessing, check("12345678903").Status)
+
+	now = now.Add(2 * time.Second)
+	assert.Equal(t, &accrual.Result{Order: "12345678903", Status: accrual.StatusProcessed, Accrual: 715}, check("12345678903"))
+	assert.Equal(t, accrual.StatusInvalid, check("12345678900").Status)
+	assert.Equal(t, accrual.StatusProcessed, check("12345678901234567890121").Status)
+	assert.Equal(t, accrual.StatusInvalid, check("12345678901234567890122").Status)
+}
+
+func TestSimulatorRateLimit(t *testing.T) {
+	now := time.Now()
+	simulator := NewSimulator(0, 0, 2)
+	simulator.now = func() time.Time { return now }
+	ts := httptest.NewServer(simulator)
+	defer ts.Close()
+	client := accrual.NewHTTPClient(ts.URL, time.Second)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		result, err := client.Check(ctx, "12345678903")
+		assert.NoError(t, err)
+		assert.Equal(t, accrual.StatusNotFound, result.Status)
+	}
+	now = now.Add(20 * time.Second)
+	result, err := client.Check(ctx, "12345678903")
+	assert.NoError(t, err)
+	assert.Equal(t, &accrual.Result{Order: "12345678903", Status: accrual.StatusThrottled, RetryAfter: 40 * time.Second, RateLimit: 2}, result)
+
+	now = now.Add(40 * time.Second)
+	result, _ = client.Check(ctx, "12345678903")
+	assert.Equal(t, accrual.StatusNotFound, result.Status)
+}
+
+func TestAddRules(t *testing.T) {
+	simulator := NewSimulator(0, 0, 0)
+	bork := &Rule{Match: "Bork", Reward: 10, RewardType: RewardPercent}
+	lg := &Rule{Match: "LG", Reward: 5, RewardType: RewardPoints}
+
+	assert.False(t, simulator.AddRules(lg, &Rule{Match: "lg", Reward: 5, RewardType: RewardPoints}))
+	assert.False(t, simulator.AddRules(lg, &Rule{Match: "Philips", Reward: 5, RewardType: "other"}))
+	assert.True(t, simulator.AddRules(bork))
+	assert.False(t, simulator.AddRules(lg, &Rule{Match: "BORK", Reward: 5, RewardType: RewardPoints}))
+	assert.Equal(t, []*Rule{bork}, simulator.rules)
+
+	assert.True(t, simulator.AddRules(lg))
+	assert.Equal(t, []*Rule{bork, lg}, simulator.rules)
+}
@@ -1,36 +1,98 @@
 package api
 
 import (
+	"expvar"
+
 	"github.com/go-chi/chi/v5"
 
+	config "github.com/nmramorov/gophemart/internal/configuration"
 	"github.com/nmramorov/gophemart/internal/db"
+	"github.com/nmramorov/gophemart/internal/deadletters"
 	"github.com/nmramorov/gophemart/internal/jobmanager"
+	"github.com/nmramorov/gophemart/internal/notifier"
 )
 
 const REQUESTTIMEOUT = 60
 
+// UserStore is what the account routes need, the users with their sessions,
+// devices and notification preferences and the referral codes they sign up
+// with.
+type UserStore interface {
+	db.UserRepository
+	db.SessionRepository
+	db.NotificationRepository
+	db.ReferralRepository
+	db.UnitOfWork
+}
+
+// OrderStore is what the order routes need, the orders of the user and the
+// campaign bonuses credited for them.
+type OrderStore interface {
+	db.SessionRepository
+	db.OrderRepository
+	db.CampaignRepository
+	db.UnitOfWork
+}
+
+// BalanceStore is what the balance routes need, the balance and the lots
+// and withdrawals it is made of and the tiers the accruals reach.
+type BalanceStore interface {
+	db.SessionRepository
+	db.BalanceRepository
+	db.WithdrawalRepository
+	db.LotRepository
+	db.TierRepository
+	db.UnitOfWork
+}
+
 type UserRouter struct {
 	*chi.Mux
-	Cursor *db.Cursor
+	Cursor      UserStore
+	Notifier    *notifier.Service
+	ReferralCap int
 }
 
 type OrderRouter struct {
 	*chi.Mux
-	Cursor  *db.Cursor
+	Cursor  OrderStore
 	Manager *jobmanager.Jobmanager
 }
 
 type BalanceRouter struct {
 	*chi.Mux
-	Cursor *db.Cursor
+	Cursor   BalanceStore
+	Notifier *notifier.Service
+}
+
+type AdminRouter struct {
+	*chi.Mux
+	Cursor db.CampaignRepository
 }
 
+type DeadLetterRouter struct {
+	*chi.Mux
+	Cursor  deadletters.Store
+	Manager *jobmanager.Jobmanager
+}
+
+type JobsRouter struct {
+	*chi.Mux
+	Cursor db.JobRepository
+}
+
+type CallbackRouter struct {
+	*chi.Mux
+	Manager *jobmanager.Jobmanager
+}
+
+// Handler wires every router to the repositories it uses, so it is the
+// only one holding all of them.
 type Handler struct {
 	*chi.Mux
 	Cursor *db.Cursor
 }
 
-func NewHandler(cursor *db.Cursor, manager *jobmanager.Jobmanager) *Handler {
+func NewHandler(cursor *db.Cursor, manager *jobmanager.Jobmanager, notifications *notifier.Service, config *config.Config) *Handler {
 	handler := &Handler{
 		Mux:    chi.NewMux(),
 		Cursor: cursor,
@@ -39,32 +101,54 @@ func NewHandler(cursor *db.Cursor, manager *jobmanager.Jobmanager) *Handler {
 	handler.Use(handler.CookieHandle)
 
 	userRouter := &UserRouter{
-		Mux:    chi.NewMux(),
-		Cursor: cursor,
+		Mux:         chi.NewMux(),
+		Cursor:      cursor,
+		Notifier:    notifications,
+		ReferralCap: config.ReferralCap,
 	}
 
 	balanceRouter := &BalanceRouter{
-		Mux:    chi.NewMux(),
-		Cursor: cursor,
+		Mux:      chi.NewMux(),
+		Cursor:   cursor,
+		Notifier: notifications,
 	}
 
 	handler.Route("/api/user", func(r chi.Router) {
 
 		r.Post("/register", userRouter.RegisterUser)
 		r.Post("/login", userRouter.Login)
+		r.Get("/referrals", userRouter.GetReferrals)
+		r.Get("/notifications", userRouter.GetNotificationPreferences)
+		r.Put("/notifications", userRouter.UpdateNotificationPreferences)
 
 		r.Get("/withdrawals", balanceRouter.GetWithdrawals)
 		r.Get("/balance", balanceRouter.GetBalance)
 		r.Post("/balance/withdraw", balanceRouter.WithdrawMoney)
+		r.Get("/tier", balanceRouter.GetTier)
 
 		OrdersRouter := NewOrdersRouter(cursor, manager)
 		r.Mount("/orders", OrdersRouter)
 	})
 
+	handler.Get("/api/health", NewHealthHandler(manager))
+
+	handler.Route("/api/internal", func(r chi.Router) {
+		r.Use(SignatureHandle(config.CallbackSecret))
+		r.Mount("/accrual", NewCallbackRouter(manager))
+	})
+
+	handler.Route("/api/admin", func(r chi.Router) {
+		r.Use(AdminHandle(config.AdminToken))
+		r.Mount("/campaigns", NewCampaignsRouter(cursor))
+		r.Mount("/dead-letters", NewDeadLettersRouter(cursor, manager))
+		r.Mount("/jobs", NewJobsRouter(cursor))
+		r.Get("/metrics", expvar.Handler().ServeHTTP)
+	})
+
 	return handler
 }
 
-func NewOrdersRouter(cursor *db.Cursor, manager *jobmanager.Jobmanager) *OrderRouter {
+func NewOrdersRouter(cursor OrderStore, manager *jobmanager.Jobmanager) *OrderRouter {
 	r := &OrderRouter{
 		Mux:     chi.NewMux(),
 		Cursor:  cursor,
@@ -72,5 +156,51 @@ func NewOrdersRouter(cursor *db.Cursor, manager *jobmanager.Jobmanager) *OrderRo
 	}
 	r.Post("/", r.UploadOrder)
 	r.Get("/", r.GetOrders)
+	r.Get("/{number}", r.GetOrder)
+	return r
+}
+
+func NewCampaignsRouter(cursor db.CampaignRepository) *AdminRouter {
+	r := &AdminRouter{
+		Mux:    chi.NewMux(),
+		Cursor: cursor,
+	}
+	r.Get("/", r.GetCampaigns)
+	r.Post("/", r.CreateCampaign)
+	r.Get("/{id}", r.GetCampaign)
+	r.Put("/{id}", r.UpdateCampaign)
+	r.Delete("/{id}", r.DeleteCampaign)
+	return r
+}
+
+func NewDeadLettersRouter(cursor deadletters.Store, manager *jobmanager.Jobmanager) *DeadLetterRouter {
+	r := &DeadLetterRouter{
+		Mux:     chi.NewMux(),
+		Cursor:  cursor,
+		Manager: manager,
+	}
+	r.Get("/", r.GetDeadLetters)
+	r.Get("/{number}", r.GetDeadLetter)
+	r.Post("/{number}/requeue", r.RequeueDeadLetter)
+	r.Delete("/{number}", r.DiscardDeadLetter)
+	return r
+}
+
+func NewJobsRouter(cursor db.JobRepository) *JobsRouter {
+	r := &JobsRouter{
+		Mux:    chi.NewMux(),
+		Cursor: cursor,
+	}
+	r.Get("/", r.GetJobs)
+	r.Get("/{number}", r.GetJob)
+	return r
+}
+
+func NewCallbackRouter(manager *jobmanager.Jobmanager) *CallbackRouter {
+	r := &CallbackRouter{
+		Mux:     chi.NewMux(),
+		Manager: manager,
+	}
+	r.Post("/callback", r.AccrualCallback)
 	return r
 }
@@ -4,17 +4,25 @@ import (
 	"bytes"
 	"encoding/json"
 	"net/http"
+	"time"
+
+	"github.com/nmramorov/gophemart/internal/expiration"
 )
 
 func (h *BalanceRouter) GetBalance(rw http.ResponseWriter, r *http.Request) {
 	cookie, _ := r.Cookie("session_token")
 	sessionToken := cookie.Value
-	username, err := h.Cursor.GetUsernameByToken(sessionToken)
+	username, err := h.Cursor.GetUsernameByToken(r.Context(), sessionToken)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	balance, err := h.Cursor.GetUserBalance(r.Context(), username)
 	if err != nil {
 		http.Error(rw, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	balance, err := h.Cursor.GetUserBalance(username)
+	balance.Expiring, err = expiration.ExpiringSum(r.Context(), h.Cursor, username, time.Now(), expiration.EXPIRINGWINDOW)
 	if err != nil {
 		http.Error(rw, err.Error(), http.StatusInternalServerError)
 		return
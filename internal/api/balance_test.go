@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -16,6 +17,7 @@ import (
 )
 
 func TestBalanceGet(t *testing.T) {
+	ctx := context.Background()
 	expectedBalance := &models.Balance{
 		Current:   500.5,
 		Withdrawn: 42,
@@ -63,12 +65,12 @@ func TestBalanceGet(t *testing.T) {
 	handler.Post("/api/user/login", ur.Login)
 	handler.Get("/api/user/balance", br.GetBalance)
 	ts := httptest.NewServer(handler)
-	handler.Cursor.SaveUserInfo(&models.UserInfo{
+	handler.Cursor.SaveUserInfo(ctx, &models.UserInfo{
 		Username: "test",
 		Password: "test",
 	})
 
-	result, _ := handler.Cursor.UpdateUserBalance(
+	result, _ := handler.Cursor.UpdateUserBalance(ctx,
 		"test", expectedBalance,
 	)
 	assert.Equal(t, expectedBalance, result)
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/nmramorov/gophemart/internal/accrual"
+	"github.com/nmramorov/gophemart/internal/logger"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+// AccrualCallback applies a batch of answers pushed by the accrual system.
+// Redelivered answers are ignored, so the accrual system may safely retry.
+func (h *CallbackRouter) AccrualCallback(rw http.ResponseWriter, r *http.Request) {
+	batch := []*models.AccrualResponse{}
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		http.Error(rw, "wrong batch format", http.StatusBadRequest)
+		return
+	}
+	summary := &models.CallbackSummary{}
+	for _, response := range batch {
+		result, err := accrual.FromResponse(response)
+		if err != nil {
+			summary.Rejected++
+			continue
+		}
+		applied, err := h.Manager.Push(r.Context(), result)
+		if err != nil {
+			logger.ErrorLog.Printf("Error applying pushed accrual for order %s: %e", result.Order, err)
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if applied {
+			summary.Applied++
+		} else {
+			summary.Ignored++
+		}
+	}
+	writeJSON(rw, http.StatusOK, summary)
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+
+	config "github.com/nmramorov/gophemart/internal/configuration"
+	"github.com/nmramorov/gophemart/internal/db"
+	"github.com/nmramorov/gophemart/internal/jobmanager"
+	"github.com/nmramorov/gophemart/internal/mocks"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+func TestAccrualCallback(t *testing.T) {
+	cursor := &db.Cursor{DBInterface: mocks.NewMock()}
+	ctx := context.Background()
+	manager := jobmanager.NewJobmanager(cursor, nil, &config.Config{
+		PointsTTL:       12,
+		CallbackSecret:  "secret",
+		CallbackTimeout: time.Minute,
+	}, &ctx)
+	defer manager.Shutdown()
+	handler := &Handler{
+		Mux:    chi.NewMux(),
+		Cursor: cursor,
+	}
+	handler.Use(handler.CookieHandle)
+	handler.Route("/api/internal", func(r chi.Router) {
+		r.Use(SignatureHandle("secret"))
+		r.Mount("/accrual", NewCallbackRouter(manager))
+	})
+
+	for _, number := range []string{"12345678903", "79927398713"} {
+		cursor.SaveOrder(ctx, &models.Order{Number: number, Username: "test", Status: models.OrderNew})
+		assert.NoError(t, manager.AddJob(ctx, number, "test"))
+	}
+	batch, _ := json.Marshal([]*models.AccrualResponse{
+		{Order: "12345678903", Status: "PROCESSED", Accrual: 500},
+		{Order: "79927398713", Status: "REGISTERED"},
+		{Order: "2377225624", Status: "PROCESSED", Accrual: 100},
+		{Order: "79927398713", Status: "LOST"},
+	})
+
+	send := func(signature string) (int, *models.CallbackSummary) {
+		request := httptest.NewRequest(http.MethodPost, "http://localhost:8080/api/internal/accrual/callback", bytes.NewReader(batch))
+		request.Header.Set(SIGNATUREHEADER, signature)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, request)
+		res := w.Result()
+		defer res.Body.Close()
+		summary := &models.CallbackSummary{}
+		json.NewDecoder(res.Body).Decode(summary)
+		return res.StatusCode, summary
+	}
+
+	code, _ := send(Sign("wrong", batch))
+	assert.Equal(t, 401, code)
+
+	code, summary := send(Sign("secret", batch))
+	assert.Equal(t, 200, code)
+	assert.Equal(t, &models.CallbackSummary{Applied: 2, Ignored: 1, Rejected: 1}, summary)
+
+	// Redelivery must not credit the order again.
+	code, summary = send(Sign("secret", batch))
+	assert.Equal(t, 200, code)
+	assert.Equal(t, &models.CallbackSummary{Applied: 1, Ignored: 2, Rejected: 1}, summary)
+
+	order, _ := cursor.GetOrder(ctx, "test", "12345678903")
+	assert.Equal(t, models.OrderProcessed, order.Status)
+	assert.Equal(t, float64(500), order.Accrual)
+	order, _ = cursor.GetOrder(ctx, "test", "79927398713")
+	assert.Equal(t, models.OrderProcessing, order.Status)
+	lots, _ := cursor.GetActiveLots(ctx, "test", time.Now())
+	assert.Len(t, lots, 1)
+}
+
+func TestSignatureHandleDisabled(t *testing.T) {
+	handler := chi.NewMux()
+	handler.Use(SignatureHandle(""))
+	handler.Post("/", func(rw http.ResponseWriter, r *http.Request) {})
+	request := httptest.NewRequest(http.MethodPost, "http://localhost:8080/", bytes.NewReader([]byte("[]")))
+	request.Header.Set(SIGNATUREHEADER, Sign("", []byte("[]")))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, request)
+	assert.Equal(t, 403, w.Code)
+}
+
+func TestSignatureHandleBodyLimit(t *testing.T) {
+	handler := chi.NewMux()
+	handler.Use(SignatureHandle("secret"))
+	handler.Post("/", func(rw http.ResponseWriter, r *http.Request) {})
+	body := bytes.Repeat([]byte(" "), CALLBACKBODYLIMIT+1)
+	request := httptest.NewRequest(http.MethodPost, "http://localhost:8080/", bytes.NewReader(body))
+	request.Header.Set(SIGNATUREHEADER, Sign("secret", body))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, request)
+	assert.Equal(t, 413, w.Code)
+
+	body = body[:CALLBACKBODYLIMIT]
+	request = httptest.NewRequest(http.MethodPost, "http://localhost:8080/", bytes.NewReader(body))
+	request.Header.Set(SIGNATUREHEADER, Sign("secret", body))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, request)
+	assert.Equal(t, 200, w.Code)
+}
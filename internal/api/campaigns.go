@@ -0,0 +1,116 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/nmramorov/gophemart/internal/campaigns"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+func writeJSON(rw http.ResponseWriter, code int, value interface{}) {
+	buff := bytes.NewBuffer([]byte{})
+	encoder := json.NewEncoder(buff)
+	encoder.Encode(value)
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(code)
+	rw.Write(buff.Bytes())
+}
+
+func campaignID(r *http.Request) (int64, error) {
+	return strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+}
+
+func (h *AdminRouter) GetCampaigns(rw http.ResponseWriter, r *http.Request) {
+	found, err := h.Cursor.GetCampaigns(r.Context())
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(rw, http.StatusOK, found)
+}
+
+func (h *AdminRouter) GetCampaign(rw http.ResponseWriter, r *http.Request) {
+	id, err := campaignID(r)
+	if err != nil {
+		http.Error(rw, "wrong campaign id", http.StatusBadRequest)
+		return
+	}
+	campaign, err := h.Cursor.GetCampaign(r.Context(), id)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if campaign == nil {
+		http.Error(rw, "campaign not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(rw, http.StatusOK, campaign)
+}
+
+func (h *AdminRouter) CreateCampaign(rw http.ResponseWriter, r *http.Request) {
+	campaign := &models.Campaign{}
+	if err := json.NewDecoder(r.Body).Decode(campaign); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := campaigns.Validate(campaign); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.Cursor.SaveCampaign(r.Context(), campaign); err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(rw, http.StatusCreated, campaign)
+}
+
+func (h *AdminRouter) UpdateCampaign(rw http.ResponseWriter, r *http.Request) {
+	id, err := campaignID(r)
+	if err != nil {
+		http.Error(rw, "wrong campaign id", http.StatusBadRequest)
+		return
+	}
+	campaign := &models.Campaign{}
+	if err := json.NewDecoder(r.Body).Decode(campaign); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	campaign.ID = id
+	if err := campaigns.Validate(campaign); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	existing, err := h.Cursor.GetCampaign(r.Context(), id)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if existing == nil {
+		http.Error(rw, "campaign not found", http.StatusNotFound)
+		return
+	}
+	if err := h.Cursor.UpdateCampaign(r.Context(), campaign); err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(rw, http.StatusOK, campaign)
+}
+
+func (h *AdminRouter) DeleteCampaign(rw http.ResponseWriter, r *http.Request) {
+	id, err := campaignID(r)
+	if err != nil {
+		http.Error(rw, "wrong campaign id", http.StatusBadRequest)
+		return
+	}
+	if err := h.Cursor.DeleteCampaign(r.Context(), id, time.Now()); err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nmramorov/gophemart/internal/db"
+	"github.com/nmramorov/gophemart/internal/mocks"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+func TestCampaignsAdmin(t *testing.T) {
+	cursor := &db.Cursor{DBInterface: mocks.NewMock()}
+	handler := &Handler{
+		Mux:    chi.NewMux(),
+		Cursor: cursor,
+	}
+	handler.Route("/api/admin", func(r chi.Router) {
+		r.Use(AdminHandle("secret"))
+		r.Mount("/campaigns", NewCampaignsRouter(cursor))
+	})
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	tests := []struct {
+		name     string
+		token    string
+		campaign *models.Campaign
+		code     int
+	}{
+		{
+			name:  "Test Negative campaign create without token",
+			token: "",
+			campaign: &models.Campaign{
+				Name: "weekend", Kind: "multiplier", Value: 2, StartsAt: now, EndsAt: now.Add(time.Hour),
+			},
+			code: 401,
+		},
+		{
+			name:  "Test Negative campaign create with wrong token",
+			token: "secre",
+			campaign: &models.Campaign{
+				Name: "weekend", Kind: "multiplier", Value: 2, StartsAt: now, EndsAt: now.Add(time.Hour),
+			},
+			code: 401,
+		},
+		{
+			name:  "Test Negative campaign create wrong kind",
+			token: "secret",
+			campaign: &models.Campaign{
+				Name: "weekend", Kind: "other", Value: 2, StartsAt: now, EndsAt: now.Add(time.Hour),
+			},
+			code: 400,
+		},
+		{
+			name:  "Test Positive campaign create",
+			token: "secret",
+			campaign: &models.Campaign{
+				Name: "weekend", Kind: "multiplier", Value: 2, StartsAt: now, EndsAt: now.Add(time.Hour),
+			},
+			code: 201,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buff := bytes.NewBuffer([]byte{})
+			json.NewEncoder(buff).Encode(tt.campaign)
+			request := httptest.NewRequest(http.MethodPost, "http://localhost:8080/api/admin/campaigns/", buff)
+			if tt.token != "" {
+				request.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, request)
+			res := w.Result()
+			defer res.Body.Close()
+			assert.Equal(t, tt.code, res.StatusCode)
+		})
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "http://localhost:8080/api/admin/campaigns/1", nil)
+	request.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, request)
+	res := w.Result()
+	defer res.Body.Close()
+	assert.Equal(t, 200, res.StatusCode)
+	found := &models.Campaign{}
+	json.NewDecoder(res.Body).Decode(found)
+	assert.Equal(t, int64(1), found.ID)
+	assert.Equal(t, "weekend", found.Name)
+
+	cursor.SaveCampaignBonus(context.Background(), &models.CampaignBonus{
+		User: "test", Order: "12345678903", CampaignID: 1, Amount: 10, CreditedAt: now,
+	}, 0)
+	request = httptest.NewRequest(http.MethodDelete, "http://localhost:8080/api/admin/campaigns/1", nil)
+	request.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, request)
+	assert.Equal(t, 204, w.Code)
+
+	request = httptest.NewRequest(http.MethodGet, "http://localhost:8080/api/admin/campaigns/1", nil)
+	request.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, request)
+	assert.Equal(t, 404, w.Code)
+	bonuses, _ := cursor.GetOrderBonuses(context.Background(), "12345678903")
+	assert.Len(t, bonuses, 1)
+	assert.Equal(t, "weekend", bonuses[0].Campaign)
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/nmramorov/gophemart/internal/deadletters"
+	"github.com/nmramorov/gophemart/internal/errors"
+)
+
+func (h *DeadLetterRouter) GetDeadLetters(rw http.ResponseWriter, r *http.Request) {
+	letters, err := h.Cursor.GetDeadLetters(r.Context())
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(rw, http.StatusOK, letters)
+}
+
+func (h *DeadLetterRouter) GetDeadLetter(rw http.ResponseWriter, r *http.Request) {
+	letter, err := deadletters.Inspect(r.Context(), h.Cursor, chi.URLParam(r, "number"))
+	if err == errors.ErrDeadLetterNotFound {
+		http.Error(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(rw, http.StatusOK, letter)
+}
+
+func (h *DeadLetterRouter) RequeueDeadLetter(rw http.ResponseWriter, r *http.Request) {
+	err := deadletters.Requeue(r.Context(), h.Cursor, chi.URLParam(r, "number"), time.Now())
+	if err == errors.ErrDeadLetterNotFound {
+		http.Error(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if h.Manager != nil {
+		h.Manager.Wake()
+	}
+	rw.WriteHeader(http.StatusAccepted)
+}
+
+func (h *DeadLetterRouter) DiscardDeadLetter(rw http.ResponseWriter, r *http.Request) {
+	err := deadletters.Discard(r.Context(), h.Cursor, chi.URLParam(r, "number"))
+	if err == errors.ErrDeadLetterNotFound {
+		http.Error(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nmramorov/gophemart/internal/db"
+	"github.com/nmramorov/gophemart/internal/mocks"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+func TestDeadLettersAdmin(t *testing.T) {
+	ctx := context.Background()
+	cursor := &db.Cursor{DBInterface: mocks.NewMock()}
+	handler := &Handler{
+		Mux:    chi.NewMux(),
+		Cursor: cursor,
+	}
+	handler.Route("/api/admin", func(r chi.Router) {
+		r.Use(AdminHandle("secret"))
+		r.Mount("/dead-letters", NewDeadLettersRouter(cursor, nil))
+	})
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	for _, number := range []string{"12345678903", "2377225624"} {
+		cursor.SaveOrder(ctx, &models.Order{Number: number, Username: "test", Status: models.OrderFailed})
+		cursor.SaveDeadLetter(ctx, &models.DeadLetter{
+			Order:     number,
+			User:      "test",
+			LastError: "unexpected accrual response",
+			Failures:  10,
+			FailedAt:  now,
+		})
+	}
+
+	call := func(method string, url string) *http.Response {
+		request := httptest.NewRequest(method, "http://localhost:8080/api/admin/dead-letters"+url, nil)
+		request.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, request)
+		return w.Result()
+	}
+
+	res := call(http.MethodGet, "/")
+	letters := []*models.DeadLetter{}
+	json.NewDecoder(res.Body).Decode(&letters)
+	res.Body.Close()
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Len(t, letters, 2)
+
+	res = call(http.MethodGet, "/12345678903")
+	letter := &models.DeadLetter{}
+	json.NewDecoder(res.Body).Decode(letter)
+	res.Body.Close()
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, "unexpected accrual response", letter.LastError)
+
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		code   int
+	}{
+		{name: "Test Negative inspect unknown", method: http.MethodGet, url: "/1", code: 404},
+		{name: "Test Positive requeue", method: http.MethodPost, url: "/12345678903/requeue", code: 202},
+		{name: "Test Negative requeue twice", method: http.MethodPost, url: "/12345678903/requeue", code: 404},
+		{name: "Test Positive discard", method: http.MethodDelete, url: "/2377225624", code: 204},
+		{name: "Test Negative discard twice", method: http.MethodDelete, url: "/2377225624", code: 404},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := call(tt.method, tt.url)
+			defer res.Body.Close()
+			assert.Equal(t, tt.code, res.StatusCode)
+		})
+	}
+
+	order, _ := cursor.GetOrder(ctx, "test", "12345678903")
+	assert.Equal(t, models.OrderNew, order.Status)
+	order, _ = cursor.GetOrder(ctx, "test", "2377225624")
+	assert.Equal(t, models.OrderFailed, order.Status)
+}
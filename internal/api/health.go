@@ -0,0 +1,34 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/nmramorov/gophemart/internal/jobmanager"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+// NewHealthHandler reports whether the service can reach the accrual
+// system. An open circuit degrades the service but does not fail it:
+// orders are still accepted and checked once the circuit closes.
+func NewHealthHandler(manager *jobmanager.Jobmanager) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		health := &models.Health{Status: "ok", Accrual: jobmanager.BreakerClosed}
+		if manager != nil && manager.Breaker != nil {
+			health.Accrual = manager.Breaker.State()
+			health.RetryIn = manager.Breaker.RetryIn().Seconds()
+		}
+		if health.Accrual != jobmanager.BreakerClosed {
+			health.Status = "degraded"
+		}
+		if manager != nil && len(manager.Providers) > 0 {
+			health.Providers = make(map[string]string)
+			for _, provider := range manager.Providers {
+				health.Providers[provider.Name] = provider.Breaker.State()
+				if provider.Breaker.State() != jobmanager.BreakerClosed {
+					health.Status = "degraded"
+				}
+			}
+		}
+		writeJSON(rw, http.StatusOK, health)
+	}
+}
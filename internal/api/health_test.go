@@ -0,0 +1,49 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nmramorov/gophemart/internal/db"
+	"github.com/nmramorov/gophemart/internal/jobmanager"
+	"github.com/nmramorov/gophemart/internal/mocks"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+func TestHealth(t *testing.T) {
+	manager := &jobmanager.Jobmanager{Breaker: jobmanager.NewBreaker(1, time.Hour)}
+	handler := &Handler{
+		Mux:    chi.NewMux(),
+		Cursor: &db.Cursor{DBInterface: mocks.NewMock()},
+	}
+	handler.Use(handler.CookieHandle)
+	handler.Get("/api/health", NewHealthHandler(manager))
+
+	check := func() *models.Health {
+		request := httptest.NewRequest(http.MethodGet, "http://localhost:8080/api/health", nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, request)
+		res := w.Result()
+		defer res.Body.Close()
+		assert.Equal(t, 200, res.StatusCode)
+		health := &models.Health{}
+		if err := json.NewDecoder(res.Body).Decode(health); err != nil {
+			panic(err)
+		}
+		return health
+	}
+
+	assert.Equal(t, &models.Health{Status: "ok", Accrual: "closed"}, check())
+
+	manager.Breaker.Failure()
+	health := check()
+	assert.Equal(t, "degraded", health.Status)
+	assert.Equal(t, "open", health.Accrual)
+	assert.Greater(t, health.RetryIn, float64(0))
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/nmramorov/gophemart/internal/jobmanager"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+const (
+	JOBSLIMIT    = 100
+	JOBSMAXLIMIT = 1000
+)
+
+func newJobInfo(job *models.AccrualJob, now time.Time) *models.JobInfo {
+	info := &models.JobInfo{
+		Order:      job.Order,
+		User:       job.User,
+		State:      job.State,
+		Attempts:   job.Attempts,
+		Failures:   job.Failures,
+		StatusCode: job.StatusCode,
+		LastError:  job.LastError,
+		CheckedAt:  job.CheckedAt,
+		Owner:      job.Owner,
+		Age:        now.Sub(job.CreatedAt).Seconds(),
+	}
+	if info.State == "" {
+		info.State = jobmanager.JobState(job)
+	}
+	if job.Status == jobmanager.JobQueued {
+		nextAttempt := job.NextRunAt
+		info.NextAttempt = &nextAttempt
+	}
+	return info
+}
+
+// GetJobs lists the queued, retrying, running and dead jobs, filtered by
+// the state query parameter and capped by limit.
+func (h *JobsRouter) GetJobs(rw http.ResponseWriter, r *http.Request) {
+	state := r.URL.Query().Get("state")
+	switch state {
+	case "", jobmanager.JobStateQueued, jobmanager.JobStateRetrying, jobmanager.JobStateRunning, jobmanager.JobStateDead:
+	default:
+		http.Error(rw, "unknown job state "+state, http.StatusBadRequest)
+		return
+	}
+	limit := JOBSLIMIT
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			http.Error(rw, "limit must be a positive number", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+	if limit > JOBSMAXLIMIT {
+		limit = JOBSMAXLIMIT
+	}
+	jobs, err := h.Cursor.GetJobs(r.Context(), state, limit)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	now := time.Now()
+	infos := make([]*models.JobInfo, 0, len(jobs))
+	for _, job := range jobs {
+		infos = append(infos, newJobInfo(job, now))
+	}
+	writeJSON(rw, http.StatusOK, infos)
+}
+
+func (h *JobsRouter) GetJob(rw http.ResponseWriter, r *http.Request) {
+	job, err := h.Cursor.GetJob(r.Context(), chi.URLParam(r, "number"))
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if job == nil {
+		http.Error(rw, "job not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(rw, http.StatusOK, newJobInfo(job, time.Now()))
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nmramorov/gophemart/internal/db"
+	"github.com/nmramorov/gophemart/internal/mocks"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+func TestJobsAdmin(t *testing.T) {
+	ctx := context.Background()
+	cursor := &db.Cursor{DBInterface: mocks.NewMock()}
+	handler := &Handler{
+		Mux:    chi.NewMux(),
+		Cursor: cursor,
+	}
+	handler.Route("/api/admin", func(r chi.Router) {
+		r.Use(AdminHandle("secret"))
+		r.Mount("/jobs", NewJobsRouter(cursor))
+	})
+
+	now := time.Now()
+	for _, number := range []string{"12345678903", "2377225624", "79927398713", "49927398716", "4561261212345467"} {
+		cursor.EnqueueJob(ctx, number, "test", now.Add(-time.Minute), now.Add(-time.Minute))
+	}
+	cursor.ClaimJobs(ctx, "a", now, now.Add(time.Minute), 3, nil, true)
+	cursor.RetryJob(ctx, "12345678903", "a", now.Add(time.Minute), "accrual answered 500", 500, now)
+	cursor.FailJob(ctx, "2377225624", "a", "unexpected accrual response", 200, now)
+	cursor.SaveOrder(ctx, &models.Order{Number: "49927398716", Username: "test", Status: models.OrderNew})
+	cursor.CreditOrder(ctx, &models.Credit{Order: "49927398716", User: "test", Status: models.OrderInvalid, StatusCode: 200, CreditedAt: now})
+
+	call := func(url string) *http.Response {
+		request := httptest.NewRequest(http.MethodGet, "http://localhost:8080/api/admin/jobs"+url, nil)
+		request.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, request)
+		return w.Result()
+	}
+	list := func(url string) []*models.JobInfo {
+		res := call(url)
+		defer res.Body.Close()
+		assert.Equal(t, 200, res.StatusCode)
+		jobs := []*models.JobInfo{}
+		json.NewDecoder(res.Body).Decode(&jobs)
+		return jobs
+	}
+
+	states := map[string]string{}
+	for _, job := range list("/") {
+		states[job.Order] = job.State
+	}
+	assert.Equal(t, map[string]string{
+		"12345678903":      "retrying",
+		"2377225624":       "dead",
+		"79927398713":      "running",
+		"4561261212345467": "queued",
+	}, states)
+
+	retrying := list("/?state=retrying")
+	assert.Len(t, retrying, 1)
+	assert.Equal(t, 500, retrying[0].StatusCode)
+	assert.Equal(t, 1, retrying[0].Attempts)
+	assert.Equal(t, 1, retrying[0].Failures)
+	assert.NotNil(t, retrying[0].CheckedAt)
+	assert.WithinDuration(t, now.Add(time.Minute), *retrying[0].NextAttempt, time.Second)
+	assert.GreaterOrEqual(t, retrying[0].Age, float64(60))
+
+	dead := list("/?state=dead")
+	assert.Len(t, dead, 1)
+	assert.Nil(t, dead[0].NextAttempt)
+	assert.Len(t, list("/?limit=2"), 2)
+
+	res := call("/?state=lost")
+	res.Body.Close()
+	assert.Equal(t, 400, res.StatusCode)
+
+	res = call("/49927398716")
+	job := &models.JobInfo{}
+	json.NewDecoder(res.Body).Decode(job)
+	res.Body.Close()
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, "done", job.State)
+	res = call("/1234567812345670")
+	res.Body.Close()
+	assert.Equal(t, 404, res.StatusCode)
+}
+
+func TestGetOrdersCheckTimes(t *testing.T) {
+	ctx := context.Background()
+	cursor := &db.Cursor{DBInterface: mocks.NewMock()}
+	handler := &Handler{
+		Mux:    chi.NewMux(),
+		Cursor: cursor,
+	}
+	handler.Mount("/api/user/orders", NewOrdersRouter(cursor, nil))
+	cursor.SaveSession(ctx, "token", &models.Session{Username: "test", ExpiresAt: time.Now().Add(time.Hour)})
+
+	now := time.Now().UTC().Truncate(time.Second)
+	for _, number := range []string{"12345678903", "2377225624"} {
+		cursor.SaveOrder(ctx, &models.Order{Number: number, Username: "test", Status: models.OrderNew, UploadedAt: now})
+		cursor.EnqueueJob(ctx, number, "test", now, now)
+	}
+	cursor.ClaimJobs(ctx, "a", now, now.Add(time.Minute), 2, nil, true)
+	cursor.RetryJob(ctx, "12345678903", "a", now.Add(time.Minute), "accrual answered 500", 500, now)
+	cursor.CreditOrder(ctx, &models.Credit{Order: "2377225624", User: "test", Status: models.OrderInvalid, StatusCode: 200, CreditedAt: now})
+
+	request := httptest.NewRequest(http.MethodGet, "http://localhost:8080/api/user/orders/", nil)
+	request.AddCookie(&http.Cookie{Name: "session_token", Value: "token"})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, request)
+	res := w.Result()
+	defer res.Body.Close()
+	assert.Equal(t, 200, res.StatusCode)
+	orders := []*models.Order{}
+	json.NewDecoder(res.Body).Decode(&orders)
+	assert.Len(t, orders, 2)
+
+	found := map[string]*models.Order{}
+	for _, order := range orders {
+		found[order.Number] = order
+	}
+	assert.True(t, now.Equal(*found["12345678903"].CheckedAt))
+	assert.True(t, now.Add(time.Minute).Equal(*found["12345678903"].NextCheck))
+	assert.True(t, now.Equal(*found["2377225624"].CheckedAt))
+	assert.Nil(t, found["2377225624"].NextCheck)
+}
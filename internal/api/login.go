@@ -1,13 +1,17 @@
 package api
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"net/http"
 	"time"
 
 	"github.com/google/uuid"
 
+	"github.com/nmramorov/gophemart/internal/logger"
 	"github.com/nmramorov/gophemart/internal/models"
+	"github.com/nmramorov/gophemart/internal/notifier"
 )
 
 func (h *UserRouter) Login(rw http.ResponseWriter, r *http.Request) {
@@ -20,7 +24,7 @@ func (h *UserRouter) Login(rw http.ResponseWriter, r *http.Request) {
 		http.Error(rw, err.Error(), http.StatusBadRequest)
 		return
 	}
-	dbData, err := h.Cursor.GetUserInfo(userInput)
+	dbData, err := h.Cursor.GetUserInfo(r.Context(), userInput)
 
 	if err != nil {
 		http.Error(rw, "wrong password/username", http.StatusUnauthorized)
@@ -33,11 +37,12 @@ func (h *UserRouter) Login(rw http.ResponseWriter, r *http.Request) {
 	sessionToken := uuid.NewString()
 	expiresAt := time.Now().Add(600 * time.Second)
 
-	h.Cursor.SaveSession(sessionToken, &models.Session{
+	h.Cursor.SaveSession(r.Context(), sessionToken, &models.Session{
 		Username:  userInput.Username,
 		ExpiresAt: expiresAt,
 		Token:     sessionToken,
 	})
+	h.checkDevice(userInput.Username, r)
 
 	http.SetCookie(rw, &http.Cookie{
 		Name:    "session_token",
@@ -48,3 +53,28 @@ func (h *UserRouter) Login(rw http.ResponseWriter, r *http.Request) {
 
 	rw.Write([]byte(`success`))
 }
+
+// DeviceFingerprint identifies the client device by its user agent.
+func DeviceFingerprint(r *http.Request) string {
+	sum := sha256.Sum256([]byte(r.UserAgent()))
+	return hex.EncodeToString(sum[:16])
+}
+
+func (h *UserRouter) checkDevice(username string, r *http.Request) {
+	fingerprint := DeviceFingerprint(r)
+	known, err := h.Cursor.HasDevice(r.Context(), username, fingerprint)
+	if err != nil {
+		logger.ErrorLog.Printf("Error checking device of %s: %e", username, err)
+		return
+	}
+	if !known {
+		h.Notifier.Notify(&notifier.Event{
+			Kind:     notifier.EventNewDevice,
+			Username: username,
+			Data: map[string]interface{}{
+				"device": r.UserAgent(),
+			},
+		})
+	}
+	h.Cursor.SaveDevice(r.Context(), username, fingerprint, time.Now())
+}
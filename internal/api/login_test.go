@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
@@ -16,6 +17,7 @@ import (
 )
 
 func TestAuthentication(t *testing.T) {
+	ctx := context.Background()
 	type want struct {
 		code     int
 		response string
@@ -109,7 +111,7 @@ func TestAuthentication(t *testing.T) {
 	}
 	ur.Post("/api/user/login", ur.Login)
 	ts := httptest.NewServer(ur)
-	ur.Cursor.SaveUserInfo(&models.UserInfo{
+	ur.Cursor.SaveUserInfo(ctx, &models.UserInfo{
 		Username: "test",
 		Password: "test",
 	})
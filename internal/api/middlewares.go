@@ -1,13 +1,25 @@
 package api
 
 import (
+	"bytes"
 	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
 	"io"
 	"net/http"
 	"strings"
 	"time"
 )
 
+const SIGNATUREHEADER = "X-Accrual-Signature"
+
+// CALLBACKBODYLIMIT is the largest signed body read before checking its
+// signature.
+const CALLBACKBODYLIMIT = 1 << 20
+
 type gzipWriter struct {
 	http.ResponseWriter
 	Writer io.Writer
@@ -46,6 +58,10 @@ func (h *Handler) CookieHandle(next http.Handler) http.Handler {
 		if strings.Contains(r.URL.Path, "/api/user/register") || strings.Contains(r.URL.Path, "/api/user/login") {
 			next.ServeHTTP(w, r)
 		}
+		if strings.HasPrefix(r.URL.Path, "/api/admin") || strings.HasPrefix(r.URL.Path, "/api/internal") || r.URL.Path == "/api/health" {
+			next.ServeHTTP(w, r)
+			return
+		}
 		c, err := r.Cookie("session_token")
 		if err != nil {
 			if err == http.ErrNoCookie {
@@ -60,7 +76,7 @@ func (h *Handler) CookieHandle(next http.Handler) http.Handler {
 		sessionToken := c.Value
 
 		// We then get the session from our session map
-		userSession, err := h.Cursor.GetSession(sessionToken)
+		userSession, err := h.Cursor.GetSession(r.Context(), sessionToken)
 		if err != nil {
 			// If the session token is not present in session map, return an unauthorized error
 			w.WriteHeader(http.StatusUnauthorized)
@@ -77,3 +93,58 @@ func (h *Handler) CookieHandle(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// AdminHandle lets through requests carrying the admin token in the
+// Authorization header. An empty token disables the admin API.
+func AdminHandle(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Sign returns the signature of body expected in SIGNATUREHEADER.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignatureHandle lets through requests whose body is signed with the
+// shared secret. An empty secret disables the endpoint, bodies over
+// CALLBACKBODYLIMIT are refused unread.
+func SignatureHandle(secret string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if secret == "" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, CALLBACKBODYLIMIT))
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if !hmac.Equal([]byte(r.Header.Get(SIGNATUREHEADER)), []byte(Sign(secret, body))) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
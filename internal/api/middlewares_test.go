@@ -8,6 +8,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	config "github.com/nmramorov/gophemart/internal/configuration"
 	"github.com/nmramorov/gophemart/internal/db"
 	"github.com/nmramorov/gophemart/internal/jobmanager"
 	"github.com/nmramorov/gophemart/internal/mocks"
@@ -16,8 +17,9 @@ import (
 func TestCookiesMiddleware(t *testing.T) {
 	cursor := &db.Cursor{DBInterface: mocks.NewMock()}
 	ctx := context.Background()
-	manager := jobmanager.NewJobmanager(cursor, "http://localhost:8081", &ctx)
-	handler := NewHandler(cursor, manager)
+	cfg := &config.Config{Accrual: "http://localhost:8081"}
+	manager := jobmanager.NewJobmanager(cursor, nil, cfg, &ctx)
+	handler := NewHandler(cursor, manager, nil, cfg)
 	ts := httptest.NewServer(handler)
 
 	defer ts.Close()
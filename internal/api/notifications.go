@@ -0,0 +1,56 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+func (h *UserRouter) GetNotificationPreferences(rw http.ResponseWriter, r *http.Request) {
+	cookie, _ := r.Cookie("session_token")
+	sessionToken := cookie.Value
+	username, err := h.Cursor.GetUsernameByToken(r.Context(), sessionToken)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	preferences, err := h.Cursor.GetNotificationPreferences(r.Context(), username)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	buff := bytes.NewBuffer([]byte{})
+	encoder := json.NewEncoder(buff)
+	encoder.Encode(preferences)
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(buff.Bytes())
+}
+
+func (h *UserRouter) UpdateNotificationPreferences(rw http.ResponseWriter, r *http.Request) {
+	preferences := &models.NotificationPreferences{}
+	if err := json.NewDecoder(r.Body).Decode(preferences); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if preferences.Email != "" && !strings.Contains(preferences.Email, "@") {
+		http.Error(rw, "wrong email format", http.StatusBadRequest)
+		return
+	}
+	cookie, _ := r.Cookie("session_token")
+	sessionToken := cookie.Value
+	username, err := h.Cursor.GetUsernameByToken(r.Context(), sessionToken)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	preferences.User = username
+	if err := h.Cursor.SaveNotificationPreferences(r.Context(), preferences); err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.WriteHeader(http.StatusOK)
+	rw.Write([]byte(`success`))
+}
@@ -2,12 +2,14 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/theplant/luhn"
 
 	"github.com/nmramorov/gophemart/internal/db"
@@ -31,7 +33,7 @@ func (h *OrderRouter) UploadOrder(rw http.ResponseWriter, r *http.Request) {
 
 	cookie, _ := r.Cookie("session_token")
 	sessionToken := cookie.Value
-	username, err := h.Cursor.GetUsernameByToken(sessionToken)
+	username, err := h.Cursor.GetUsernameByToken(r.Context(), sessionToken)
 	if err != nil {
 		http.Error(rw, err.Error(), http.StatusInternalServerError)
 	}
@@ -48,7 +50,7 @@ func (h *OrderRouter) UploadOrder(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	order, err := GetOrderFromDB(h.Cursor, username, requestNumber)
+	order, err := GetOrderFromDB(r.Context(), h.Cursor, username, requestNumber)
 	if err != nil {
 		http.Error(rw, err.Error(), http.StatusInternalServerError)
 		return
@@ -59,20 +61,25 @@ func (h *OrderRouter) UploadOrder(rw http.ResponseWriter, r *http.Request) {
 			Number:     requestNumber,
 			Username:   username,
 			UploadedAt: time.Now(),
-			Status:     "NEW",
+			Status:     models.OrderNew,
 		}
-		err := ValidateOrder(h.Cursor, newOrder)
+		err := ValidateOrder(r.Context(), h.Cursor, newOrder)
 		if err != nil {
 			logger.ErrorLog.Printf("Validation error for new order %s, token %s", newOrder.Number, sessionToken)
 			http.Error(rw, "order was uploaded already by another user", http.StatusConflict)
 			return
 		}
-		h.Cursor.SaveOrder(newOrder)
-		err = h.Manager.AddJob(requestNumber, username)
+		err = h.Cursor.WithTx(r.Context(), func(tx db.Repos) error {
+			if err := tx.SaveOrder(r.Context(), newOrder); err != nil {
+				return err
+			}
+			return h.Manager.Enqueue(r.Context(), tx, requestNumber, username)
+		})
 		if err != nil {
 			http.Error(rw, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		h.Manager.Wake()
 		rw.WriteHeader(http.StatusAccepted)
 		rw.Write([]byte(`new order created`))
 		return
@@ -92,8 +99,8 @@ func (h *OrderRouter) UploadOrder(rw http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func GetOrderFromDB(cursor *db.Cursor, username string, requestOrder string) (*models.Order, error) {
-	order, err := cursor.GetOrder(username, requestOrder)
+func GetOrderFromDB(ctx context.Context, cursor db.OrderRepository, username string, requestOrder string) (*models.Order, error) {
+	order, err := cursor.GetOrder(ctx, username, requestOrder)
 	if order == nil {
 		return nil, err
 	}
@@ -103,12 +110,12 @@ func GetOrderFromDB(cursor *db.Cursor, username string, requestOrder string) (*m
 func (h *OrderRouter) GetOrders(rw http.ResponseWriter, r *http.Request) {
 	cookie, _ := r.Cookie("session_token")
 	sessionToken := cookie.Value
-	username, err := h.Cursor.GetUsernameByToken(sessionToken)
+	username, err := h.Cursor.GetUsernameByToken(r.Context(), sessionToken)
 	if err != nil {
 		http.Error(rw, err.Error(), http.StatusInternalServerError)
 	}
 
-	orders, err := h.Cursor.GetOrders(username)
+	orders, err := h.Cursor.GetOrders(r.Context(), username)
 	if err != nil {
 		http.Error(rw, err.Error(), http.StatusInternalServerError)
 	}
@@ -124,3 +131,34 @@ func (h *OrderRouter) GetOrders(rw http.ResponseWriter, r *http.Request) {
 		rw.Write(body.Bytes())
 	}
 }
+
+func (h *OrderRouter) GetOrder(rw http.ResponseWriter, r *http.Request) {
+	cookie, _ := r.Cookie("session_token")
+	sessionToken := cookie.Value
+	username, err := h.Cursor.GetUsernameByToken(r.Context(), sessionToken)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	order, err := GetOrderFromDB(r.Context(), h.Cursor, username, chi.URLParam(r, "number"))
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if order == nil {
+		http.Error(rw, "order not found", http.StatusNotFound)
+		return
+	}
+	order.Campaigns, err = h.Cursor.GetOrderBonuses(r.Context(), order.Number)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	body := bytes.NewBuffer([]byte{})
+	encoder := json.NewEncoder(body)
+	encoder.Encode(order)
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusOK)
+	rw.Write(body.Bytes())
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
@@ -18,6 +19,7 @@ import (
 )
 
 func TestPostOrders(t *testing.T) {
+	ctx := context.Background()
 	type want struct {
 		code     int
 		response string
@@ -115,7 +117,7 @@ func TestPostOrders(t *testing.T) {
 	handler.Post("/api/user/login", ur.Login)
 	handler.Post("/api/user/orders", r.UploadOrder)
 	ts := httptest.NewServer(handler)
-	handler.Cursor.SaveUserInfo(&models.UserInfo{
+	handler.Cursor.SaveUserInfo(ctx, &models.UserInfo{
 		Username: "test",
 		Password: "test",
 	})
@@ -185,6 +187,7 @@ func TestPostOrders(t *testing.T) {
 }
 
 func TestGetOrders(t *testing.T) {
+	ctx := context.Background()
 	type want struct {
 		code     int
 		response string
@@ -270,7 +273,7 @@ func TestGetOrders(t *testing.T) {
 			w := httptest.NewRecorder()
 			if tt.name == "Test Positive order get" {
 				for _, order := range orders {
-					handler.Cursor.SaveOrder(order)
+					handler.Cursor.SaveOrder(ctx, order)
 				}
 			}
 			handler.ServeHTTP(w, request)
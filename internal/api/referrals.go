@@ -0,0 +1,37 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+func (h *UserRouter) GetReferrals(rw http.ResponseWriter, r *http.Request) {
+	cookie, _ := r.Cookie("session_token")
+	sessionToken := cookie.Value
+	username, err := h.Cursor.GetUsernameByToken(r.Context(), sessionToken)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	code, err := h.Cursor.GetReferralCode(r.Context(), username)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	invitees, err := h.Cursor.GetReferrals(r.Context(), username)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	buff := bytes.NewBuffer([]byte{})
+	encoder := json.NewEncoder(buff)
+	encoder.Encode(&models.ReferralsInfo{
+		Code:     code,
+		Invitees: invitees,
+	})
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(buff.Bytes())
+}
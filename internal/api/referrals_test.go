@@ -0,0 +1,76 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nmramorov/gophemart/internal/db"
+	"github.com/nmramorov/gophemart/internal/mocks"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+func TestReferrals(t *testing.T) {
+	ctx := context.Background()
+	cursor := &db.Cursor{DBInterface: mocks.NewMock()}
+	handler := &Handler{
+		Mux:    chi.NewMux(),
+		Cursor: cursor,
+	}
+	ur := &UserRouter{
+		Mux:         chi.NewMux(),
+		Cursor:      cursor,
+		ReferralCap: 10,
+	}
+	handler.Post("/api/user/register", ur.RegisterUser)
+	handler.Get("/api/user/referrals", ur.GetReferrals)
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	register := func(info *models.UserInfo) *http.Response {
+		buff := bytes.NewBuffer([]byte{})
+		json.NewEncoder(buff).Encode(info)
+		request := httptest.NewRequest(http.MethodPost, "http://localhost:8080/api/user/register", buff)
+		request.Header.Add("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, request)
+		return w.Result()
+	}
+
+	res := register(&models.UserInfo{Username: "alice", Password: "test"})
+	defer res.Body.Close()
+	assert.Equal(t, 200, res.StatusCode)
+	cookies := res.Cookies()
+
+	code, _ := cursor.GetReferralCode(ctx, "alice")
+	assert.NotEmpty(t, code)
+
+	res = register(&models.UserInfo{Username: "bob", Password: "test", ReferralCode: "unknown"})
+	defer res.Body.Close()
+	assert.Equal(t, 400, res.StatusCode)
+
+	res = register(&models.UserInfo{Username: "bob", Password: "test", ReferralCode: code})
+	defer res.Body.Close()
+	assert.Equal(t, 200, res.StatusCode)
+
+	request := httptest.NewRequest(http.MethodGet, "http://localhost:8080/api/user/referrals", nil)
+	request.AddCookie(cookies[0])
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, request)
+	res = w.Result()
+	defer res.Body.Close()
+	assert.Equal(t, 200, res.StatusCode)
+
+	info := &models.ReferralsInfo{}
+	json.NewDecoder(res.Body).Decode(info)
+	assert.Equal(t, code, info.Code)
+	assert.Equal(t, 1, len(info.Invitees))
+	assert.Equal(t, "bob", info.Invitees[0].Referee)
+	assert.Equal(t, "PENDING", info.Invitees[0].Status)
+}
@@ -7,7 +7,9 @@ import (
 
 	"github.com/google/uuid"
 
+	"github.com/nmramorov/gophemart/internal/db"
 	"github.com/nmramorov/gophemart/internal/models"
+	"github.com/nmramorov/gophemart/internal/referrals"
 )
 
 func (h *UserRouter) RegisterUser(rw http.ResponseWriter, r *http.Request) {
@@ -20,23 +22,59 @@ func (h *UserRouter) RegisterUser(rw http.ResponseWriter, r *http.Request) {
 		http.Error(rw, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if err := h.Cursor.SaveUserInfo(userInput); err != nil {
-		http.Error(rw, "user already exists", http.StatusConflict)
-		return
+	var referrer string
+	if userInput.ReferralCode != "" {
+		var err error
+		referrer, err = referrals.ResolveCode(r.Context(), h.Cursor, userInput.ReferralCode)
+		if err != nil {
+			http.Error(rw, "unknown referral code", http.StatusBadRequest)
+			return
+		}
+		if referrer == userInput.Username {
+			http.Error(rw, "self-referral is not allowed", http.StatusBadRequest)
+			return
+		}
 	}
 	sessionToken := uuid.NewString()
 	expiresAt := time.Now().Add(600 * time.Second)
-
-	h.Cursor.SaveSession(sessionToken, &models.Session{
-		Username:  userInput.Username,
-		ExpiresAt: expiresAt,
-		Token:     sessionToken,
-	})
-	h.Cursor.SaveUserBalance(userInput.Username, &models.Balance{
-		User:      userInput.Username,
-		Current:   0.0,
-		Withdrawn: 0.0,
+	exists := false
+	err := h.Cursor.WithTx(r.Context(), func(tx db.Repos) error {
+		if err := tx.SaveUserInfo(r.Context(), userInput); err != nil {
+			exists = true
+			return err
+		}
+		if _, err := tx.SaveUserBalance(r.Context(), userInput.Username, &models.Balance{
+			User:      userInput.Username,
+			Current:   0.0,
+			Withdrawn: 0.0,
+		}); err != nil {
+			return err
+		}
+		if err := tx.SaveSession(r.Context(), sessionToken, &models.Session{
+			Username:  userInput.Username,
+			ExpiresAt: expiresAt,
+			Token:     sessionToken,
+		}); err != nil {
+			return err
+		}
+		if err := tx.SaveReferralCode(r.Context(), userInput.Username, referrals.NewCode()); err != nil {
+			return err
+		}
+		if referrer == "" {
+			return nil
+		}
+		_, err := referrals.Register(r.Context(), tx, referrer, userInput.Username, h.ReferralCap, time.Now())
+		return err
 	})
+	if exists {
+		http.Error(rw, "user already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.Cursor.SaveDevice(r.Context(), userInput.Username, DeviceFingerprint(r), time.Now())
 
 	http.SetCookie(rw, &http.Cookie{
 		Name:    "session_token",
@@ -0,0 +1,30 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/nmramorov/gophemart/internal/tiers"
+)
+
+func (h *BalanceRouter) GetTier(rw http.ResponseWriter, r *http.Request) {
+	cookie, _ := r.Cookie("session_token")
+	sessionToken := cookie.Value
+	username, err := h.Cursor.GetUsernameByToken(r.Context(), sessionToken)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	status, err := tiers.ForUser(r.Context(), h.Cursor, username, time.Now())
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	buff := bytes.NewBuffer([]byte{})
+	encoder := json.NewEncoder(buff)
+	encoder.Encode(status)
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Write(buff.Bytes())
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nmramorov/gophemart/internal/db"
+	"github.com/nmramorov/gophemart/internal/mocks"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+func TestTierGet(t *testing.T) {
+	ctx := context.Background()
+	cursor := &db.Cursor{DBInterface: mocks.NewMock()}
+	handler := &Handler{
+		Mux:    chi.NewMux(),
+		Cursor: cursor,
+	}
+	br := &BalanceRouter{
+		Mux:    chi.NewMux(),
+		Cursor: cursor,
+	}
+	handler.Get("/api/user/tier", br.GetTier)
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	cursor.SaveSession(ctx, "token", &models.Session{
+		Username:  "test",
+		Token:     "token",
+		ExpiresAt: time.Now().Add(time.Minute),
+	})
+	cursor.SaveAccrualLot(ctx, &models.AccrualLot{
+		User:      "test",
+		Order:     "2377225624",
+		Amount:    250,
+		Base:      250,
+		AccruedAt: time.Now().Add(-time.Hour),
+	})
+
+	request := httptest.NewRequest(http.MethodGet, "http://localhost:8080/api/user/tier", nil)
+	request.AddCookie(&http.Cookie{Name: "session_token", Value: "token"})
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, request)
+	res := w.Result()
+	defer res.Body.Close()
+
+	assert.Equal(t, 200, res.StatusCode)
+	status := &models.TierStatus{}
+	if err := json.NewDecoder(res.Body).Decode(status); err != nil {
+		panic(err)
+	}
+	assert.Equal(t, &models.TierStatus{
+		Tier:       "bronze",
+		Multiplier: 1,
+		Accrued:    250,
+		NextTier:   "silver",
+		ToNextTier: 750,
+	}, status)
+}
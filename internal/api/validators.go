@@ -1,6 +1,8 @@
 package api
 
 import (
+	"context"
+
 	"github.com/nmramorov/gophemart/internal/db"
 	"github.com/nmramorov/gophemart/internal/errors"
 	"github.com/nmramorov/gophemart/internal/models"
@@ -23,8 +25,8 @@ func ValidateLogin(input *models.UserInfo, existingInfo *models.UserInfo) error
 	return errors.ErrValidation
 }
 
-func ValidateOrder(cursor *db.Cursor, newOrder *models.Order) error {
-	orders, err := cursor.GetAllOrders()
+func ValidateOrder(ctx context.Context, cursor db.OrderRepository, newOrder *models.Order) error {
+	orders, err := cursor.GetAllOrders(ctx)
 	if err != nil {
 		return err
 	}
@@ -6,7 +6,11 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/nmramorov/gophemart/internal/db"
+	"github.com/nmramorov/gophemart/internal/errors"
+	"github.com/nmramorov/gophemart/internal/expiration"
 	"github.com/nmramorov/gophemart/internal/models"
+	"github.com/nmramorov/gophemart/internal/notifier"
 )
 
 func (h *BalanceRouter) WithdrawMoney(rw http.ResponseWriter, r *http.Request) {
@@ -18,37 +22,45 @@ func (h *BalanceRouter) WithdrawMoney(rw http.ResponseWriter, r *http.Request) {
 
 	cookie, _ := r.Cookie("session_token")
 	sessionToken := cookie.Value
-	username, err := h.Cursor.GetUsernameByToken(sessionToken)
+	username, err := h.Cursor.GetUsernameByToken(r.Context(), sessionToken)
 	if err != nil {
 		http.Error(rw, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	userBalance, err := h.Cursor.GetUserBalance(username)
+	userBalance, err := h.Cursor.GetUserBalance(r.Context(), username)
 	if err != nil {
 		http.Error(rw, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	resultedAccrual := userBalance.Current - withrawal.Sum
-	if resultedAccrual < 0 {
+	if userBalance.Current < withrawal.Sum {
 		http.Error(rw, "not enough money", http.StatusPaymentRequired)
 		return
 	}
-	resultedWithdrawn := userBalance.Withdrawn + withrawal.Sum
-	h.Cursor.SaveWithdrawal(&models.Withdrawal{
-		User:        username,
-		Order:       withrawal.Order,
-		Sum:         withrawal.Sum,
-		ProcessedAt: time.Now(),
-	})
-	_, err = h.Cursor.UpdateUserBalance(username, &models.Balance{
-		User:      username,
-		Current:   resultedAccrual,
-		Withdrawn: resultedWithdrawn,
+	err = h.Cursor.WithTx(r.Context(), func(tx db.Repos) error {
+		return expiration.Withdraw(r.Context(), tx, &models.Withdrawal{
+			User:        username,
+			Order:       withrawal.Order,
+			Sum:         withrawal.Sum,
+			ProcessedAt: time.Now(),
+		})
 	})
+	if err == errors.ErrInsufficientBalance {
+		http.Error(rw, "not enough money", http.StatusPaymentRequired)
+		return
+	}
 	if err != nil {
 		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
 	}
+	h.Notifier.Notify(&notifier.Event{
+		Kind:     notifier.EventWithdrawal,
+		Username: username,
+		Data: map[string]interface{}{
+			"order": withrawal.Order,
+			"sum":   withrawal.Sum,
+		},
+	})
 
 	rw.WriteHeader(http.StatusOK)
 	rw.Write([]byte(`success`))
@@ -57,12 +69,12 @@ func (h *BalanceRouter) WithdrawMoney(rw http.ResponseWriter, r *http.Request) {
 func (h *BalanceRouter) GetWithdrawals(rw http.ResponseWriter, r *http.Request) {
 	cookie, _ := r.Cookie("session_token")
 	sessionToken := cookie.Value
-	username, err := h.Cursor.GetUsernameByToken(sessionToken)
+	username, err := h.Cursor.GetUsernameByToken(r.Context(), sessionToken)
 	if err != nil {
 		http.Error(rw, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	withdrawals, err := h.Cursor.GetWithdrawals(username)
+	withdrawals, err := h.Cursor.GetWithdrawals(r.Context(), username)
 	if err != nil {
 		http.Error(rw, err.Error(), http.StatusInternalServerError)
 	}
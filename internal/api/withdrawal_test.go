@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
@@ -18,6 +19,7 @@ import (
 )
 
 func TestWithdrawal(t *testing.T) {
+	ctx := context.Background()
 	type want struct {
 		code     int
 		response string
@@ -95,17 +97,17 @@ func TestWithdrawal(t *testing.T) {
 	handler.Post("/api/user/login", ur.Login)
 	handler.Post("/api/user/balance/withdraw", br.WithdrawMoney)
 	ts := httptest.NewServer(handler)
-	handler.Cursor.SaveUserInfo(&models.UserInfo{
+	handler.Cursor.SaveUserInfo(ctx, &models.UserInfo{
 		Username: "test",
 		Password: "test",
 	})
-	handler.Cursor.SaveOrder(
+	handler.Cursor.SaveOrder(ctx,
 		&models.Order{
 			Number:     "2377225624",
 			UploadedAt: time.Now(),
 		},
 	)
-	handler.Cursor.UpdateUserBalance(
+	handler.Cursor.UpdateUserBalance(ctx,
 		"test", &models.Balance{
 			User:      "test",
 			Current:   752,
@@ -164,6 +166,7 @@ func TestWithdrawal(t *testing.T) {
 }
 
 func TestGetWithdrawal(t *testing.T) {
+	ctx := context.Background()
 	layout := "2006-01-02T15:04:05Z07:00"
 	parseTime := func(layout string, toParse string) time.Time {
 		parsed, _ := time.Parse(layout, toParse)
@@ -236,13 +239,13 @@ func TestGetWithdrawal(t *testing.T) {
 	handler.Get("/api/user/withdrawals", br.GetWithdrawals)
 	handler.Post("/api/user/register", ur.RegisterUser)
 	ts := httptest.NewServer(handler)
-	handler.Cursor.SaveUserInfo(&models.UserInfo{
+	handler.Cursor.SaveUserInfo(ctx, &models.UserInfo{
 		Username: "test",
 		Password: "test",
 	})
 	for _, withdrawal := range mockWithdrawals {
 		withdrawal.User = "test"
-		handler.Cursor.SaveWithdrawal(withdrawal)
+		handler.Cursor.SaveWithdrawal(ctx, withdrawal)
 		withdrawal.User = ""
 	}
 
@@ -3,48 +3,103 @@ package app
 import (
 	"context"
 	"net/http"
+	"os/signal"
+	"syscall"
 
 	"github.com/nmramorov/gophemart/internal/api"
 	config "github.com/nmramorov/gophemart/internal/configuration"
 	"github.com/nmramorov/gophemart/internal/db"
+	"github.com/nmramorov/gophemart/internal/expiration"
 	"github.com/nmramorov/gophemart/internal/jobmanager"
 	"github.com/nmramorov/gophemart/internal/logger"
+	"github.com/nmramorov/gophemart/internal/notifier"
 )
 
 type App struct {
-	config  *config.Config
-	manager *jobmanager.Jobmanager
-	Server  *http.Server
+	config        *config.Config
+	cursor        *db.Cursor
+	manager       *jobmanager.Jobmanager
+	sweeper       *expiration.Sweeper
+	notifications *notifier.Service
+	Server        *http.Server
 }
 
+// Run serves until SIGINT or SIGTERM arrives or the server fails, then
+// shuts everything down gracefully.
 func (a *App) Run() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 	go a.manager.ManageJobs(a.config.Accrual)
-	err := a.Server.ListenAndServe()
+	go a.sweeper.Run()
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- a.Server.ListenAndServe()
+	}()
+	var err error
+	select {
+	case <-ctx.Done():
+		logger.InfoLog.Println("Received shutdown signal")
+	case err = <-serverErr:
+	}
+	a.Shutdown()
 	if err != nil && err != http.ErrServerClosed {
-		logger.InfoLog.Println("Shutting down jobmanager")
-		a.manager.Shutdown()
 		logger.ErrorLog.Fatalf("Server error: %e", err)
 	}
 }
 
+// Shutdown stops accepting requests, waits for the in-flight ones, drains
+// the jobmanager, delivers the queued notifications and closes the
+// database, all within ShutdownTimeout. The database stays open when jobs or
+// notifications are still running after that, they are cut off by the
+// process exit instead of failing on a closed pool.
+func (a *App) Shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), a.config.ShutdownTimeout)
+	defer cancel()
+	logger.InfoLog.Println("Shutting down server")
+	if err := a.Server.Shutdown(ctx); err != nil {
+		logger.ErrorLog.Printf("Error shutting down server: %e", err)
+	}
+	a.sweeper.Shutdown()
+	logger.InfoLog.Println("Shutting down jobmanager")
+	stopped := true
+	if err := a.manager.Stop(ctx); err != nil {
+		logger.ErrorLog.Printf("Error stopping jobmanager: %e", err)
+		stopped = false
+	}
+	if err := a.notifications.Close(ctx); err != nil {
+		logger.ErrorLog.Printf("Error delivering queued notifications: %e", err)
+		stopped = false
+	}
+	if !stopped {
+		logger.ErrorLog.Println("Leaving the database open for the work still running")
+		return
+	}
+	a.cursor.Close()
+}
+
 func NewApp(config *config.Config) (*App, error) {
 	logger.InfoLog.Printf("Application is running on addr %s", config.Address)
 	logger.InfoLog.Printf("Accrual addr is %s", config.Accrual)
 	logger.InfoLog.Printf("DB addr is %s", config.DatabaseURI)
 	ctx := context.Background()
-	cursor, err := db.GetCursor(config.DatabaseURI)
+	cursor, err := db.GetCursor(config)
 	if err != nil {
 		return nil, err
 	}
-	manager := jobmanager.NewJobmanager(cursor, config.Accrual, &ctx)
-	handler := api.NewHandler(cursor, manager)
+	notifications := notifier.NewService(cursor, notifier.NewSink(config))
+	manager := jobmanager.NewJobmanager(cursor, notifications, config, &ctx)
+	sweeper := expiration.NewSweeper(cursor, config.ExpirySweep, &ctx)
+	handler := api.NewHandler(cursor, manager, notifications, config)
 	server := &http.Server{
 		Addr:    config.Address,
 		Handler: handler,
 	}
 	return &App{
-		config:  config,
-		manager: manager,
-		Server:  server,
+		config:        config,
+		cursor:        cursor,
+		manager:       manager,
+		sweeper:       sweeper,
+		notifications: notifications,
+		Server:        server,
 	}, nil
 }
@@ -0,0 +1,132 @@
+package campaigns
+
+import (
+	"context"
+	"time"
+
+	"github.com/nmramorov/gophemart/internal/db"
+	"github.com/nmramorov/gophemart/internal/errors"
+	"github.com/nmramorov/gophemart/internal/logger"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+const (
+	// KindMultiplier campaigns multiply the accrual by Value, so "double
+	// points" is a Value of 2 and credits the extra accrual as bonus.
+	KindMultiplier = "multiplier"
+	// KindFixed campaigns credit Value points per order.
+	KindFixed = "fixed"
+)
+
+// Store is what applying the campaigns to an order needs, the campaigns,
+// the orders of the user and the balance the bonuses are credited to.
+type Store interface {
+	db.CampaignRepository
+	db.OrderRepository
+	db.BalanceRepository
+}
+
+func Validate(campaign *models.Campaign) error {
+	if campaign.Name == "" || campaign.Value <= 0 || campaign.PerUserCap < 0 {
+		return errors.ErrValidation
+	}
+	if campaign.Kind != KindMultiplier && campaign.Kind != KindFixed {
+		return errors.ErrValidation
+	}
+	if !campaign.EndsAt.After(campaign.StartsAt) {
+		return errors.ErrValidation
+	}
+	return nil
+}
+
+// Bonus returns the points campaign grants on top of accrual.
+func Bonus(campaign *models.Campaign, accrual float64) float64 {
+	switch campaign.Kind {
+	case KindMultiplier:
+		return accrual * (campaign.Value - 1)
+	case KindFixed:
+		return campaign.Value
+	}
+	return 0
+}
+
+func isFirstOrder(ctx context.Context, repos db.OrderRepository, username string, number string) (bool, error) {
+	orders, err := repos.GetOrders(ctx, username)
+	if err != nil {
+		return false, err
+	}
+	for _, order := range orders {
+		if order.Number != number && order.Status == models.OrderProcessed {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Apply evaluates the active campaigns for a PROCESSED order and credits a
+// bonus ledger entry per matching campaign. Campaigns already applied to the
+// order or over their per-user cap are skipped by the insert itself, so
+// Apply may run more than once for the same order. Run it in the unit of
+// work crediting the order, so the bonuses and their points are stored
+// together with the accrual.
+func Apply(ctx context.Context, repos Store, username string, response *models.AccrualResponse, now time.Time) ([]*models.CampaignBonus, error) {
+	active, err := repos.GetActiveCampaigns(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := repos.GetOrderBonuses(ctx, response.Order)
+	if err != nil {
+		return nil, err
+	}
+	credited := []*models.CampaignBonus{}
+	var total float64
+	for _, campaign := range active {
+		if hasBonus(applied, campaign.ID) {
+			continue
+		}
+		if campaign.FirstOrderOnly {
+			first, err := isFirstOrder(ctx, repos, username, response.Order)
+			if err != nil {
+				return credited, err
+			}
+			if !first {
+				continue
+			}
+		}
+		amount := Bonus(campaign, response.Accrual)
+		if amount <= 0 {
+			continue
+		}
+		bonus := &models.CampaignBonus{
+			User:       username,
+			Order:      response.Order,
+			CampaignID: campaign.ID,
+			Campaign:   campaign.Name,
+			Amount:     amount,
+			CreditedAt: now,
+		}
+		saved, err := repos.SaveCampaignBonus(ctx, bonus, campaign.PerUserCap)
+		if err != nil {
+			return credited, err
+		}
+		if !saved {
+			continue
+		}
+		logger.InfoLog.Printf("Campaign %d credited %f points for order %s", campaign.ID, amount, response.Order)
+		credited = append(credited, bonus)
+		total += amount
+	}
+	if total == 0 {
+		return credited, nil
+	}
+	return credited, repos.CreditBalance(ctx, username, total)
+}
+
+func hasBonus(bonuses []*models.CampaignBonus, campaignID int64) bool {
+	for _, bonus := range bonuses {
+		if bonus.CampaignID == campaignID {
+			return true
+		}
+	}
+	return false
+}
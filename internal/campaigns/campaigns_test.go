@@ -0,0 +1,71 @@
+package campaigns
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nmramorov/gophemart/internal/db"
+	"github.com/nmramorov/gophemart/internal/mocks"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+func TestApply(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	cursor := &db.Cursor{DBInterface: mocks.NewMock()}
+	cursor.SaveUserBalance(ctx, "test", &models.Balance{User: "test", Current: 100})
+	cursor.SaveCampaign(ctx, &models.Campaign{
+		Name:     "double points",
+		Kind:     KindMultiplier,
+		Value:    2,
+		StartsAt: now.Add(-time.Hour),
+		EndsAt:   now.Add(time.Hour),
+	})
+	cursor.SaveCampaign(ctx, &models.Campaign{
+		Name:           "first order",
+		Kind:           KindFixed,
+		Value:          100,
+		FirstOrderOnly: true,
+		PerUserCap:     1,
+		StartsAt:       now.Add(-time.Hour),
+		EndsAt:         now.Add(time.Hour),
+	})
+	cursor.SaveCampaign(ctx, &models.Campaign{
+		Name:     "expired",
+		Kind:     KindFixed,
+		Value:    500,
+		StartsAt: now.Add(-2 * time.Hour),
+		EndsAt:   now.Add(-time.Hour),
+	})
+	cursor.SaveOrder(ctx, &models.Order{Number: "1", Username: "test", Status: models.OrderProcessed, Accrual: 100})
+
+	credited, err := Apply(ctx, cursor, "test", &models.AccrualResponse{Order: "1", Status: "PROCESSED", Accrual: 100}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(credited))
+	balance, _ := cursor.GetUserBalance(ctx, "test")
+	assert.Equal(t, float64(300), balance.Current)
+
+	credited, err = Apply(ctx, cursor, "test", &models.AccrualResponse{Order: "1", Status: "PROCESSED", Accrual: 100}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(credited))
+	balance, _ = cursor.GetUserBalance(ctx, "test")
+	assert.Equal(t, float64(300), balance.Current)
+
+	cursor.SaveOrder(ctx, &models.Order{Number: "2", Username: "test", Status: models.OrderProcessed, Accrual: 50})
+	credited, err = Apply(ctx, cursor, "test", &models.AccrualResponse{Order: "2", Status: "PROCESSED", Accrual: 50}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(credited))
+	assert.Equal(t, "double points", credited[0].Campaign)
+	balance, _ = cursor.GetUserBalance(ctx, "test")
+	assert.Equal(t, float64(350), balance.Current)
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Now()
+	assert.NoError(t, Validate(&models.Campaign{Name: "c", Kind: KindFixed, Value: 1, StartsAt: now, EndsAt: now.Add(time.Hour)}))
+	assert.Error(t, Validate(&models.Campaign{Name: "c", Kind: "other", Value: 1, StartsAt: now, EndsAt: now.Add(time.Hour)}))
+	assert.Error(t, Validate(&models.Campaign{Name: "c", Kind: KindFixed, Value: 1, StartsAt: now, EndsAt: now}))
+}
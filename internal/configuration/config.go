@@ -1,16 +1,92 @@
 package configuration
 
+import "time"
+
 type Config struct {
-	Address     string
-	DatabaseURI string
-	Accrual     string
+	Address          string
+	DatabaseURI      string
+	Accrual          string
+	PointsTTL        int
+	ExpirySweep      time.Duration
+	AdminToken       string
+	ReferralBonus    float64
+	ReferralCap      int
+	Notifier         string
+	NotifyFile       string
+	SMTPAddress      string
+	SMTPFrom         string
+	SMTPUsername     string
+	SMTPPassword     string
+	Workers          int
+	QueueSize        int
+	AccrualRateLimit int
+	RetryBase        time.Duration
+	RetryMax         time.Duration
+	RetryMaxAttempts int
+	RetryMaxAge      time.Duration
+	BreakerThreshold int
+	BreakerTimeout   time.Duration
+	ShutdownTimeout  time.Duration
+	JobTimeout       time.Duration
+	AccrualTimeout   time.Duration
+	CallbackSecret   string
+	CallbackTimeout  time.Duration
+	Providers        Providers
+	PollBatchSize    int
+	PollMaxInterval  time.Duration
+	InstanceID       string
+	JobLease         time.Duration
+	QueryTimeout     time.Duration
+	DBMaxConns       int
+	DBMinConns       int
+	DBConnLifetime   time.Duration
+	DBConnIdleTime   time.Duration
+	DBHealthCheck    time.Duration
+	DBStatementCache int
 }
 
 func NewConfig(flags *CLIOptions, envs *EnvConfig) *Config {
 	result := &Config{
-		Address:     flags.Address,
-		Accrual:     flags.Accrual,
-		DatabaseURI: flags.DatabaseURI,
+		Address:          flags.Address,
+		Accrual:          flags.Accrual,
+		DatabaseURI:      flags.DatabaseURI,
+		PointsTTL:        envs.PointsTTL,
+		ExpirySweep:      envs.ExpirySweep,
+		AdminToken:       envs.AdminToken,
+		ReferralBonus:    envs.ReferralBonus,
+		ReferralCap:      envs.ReferralCap,
+		Notifier:         envs.Notifier,
+		NotifyFile:       envs.NotifyFile,
+		SMTPAddress:      envs.SMTPAddress,
+		SMTPFrom:         envs.SMTPFrom,
+		SMTPUsername:     envs.SMTPUsername,
+		SMTPPassword:     envs.SMTPPassword,
+		Workers:          envs.Workers,
+		QueueSize:        envs.QueueSize,
+		AccrualRateLimit: envs.AccrualRateLimit,
+		RetryBase:        envs.RetryBase,
+		RetryMax:         envs.RetryMax,
+		RetryMaxAttempts: envs.RetryMaxAttempts,
+		RetryMaxAge:      envs.RetryMaxAge,
+		BreakerThreshold: envs.BreakerThreshold,
+		BreakerTimeout:   envs.BreakerTimeout,
+		ShutdownTimeout:  envs.ShutdownTimeout,
+		JobTimeout:       envs.JobTimeout,
+		AccrualTimeout:   envs.AccrualTimeout,
+		CallbackSecret:   envs.CallbackSecret,
+		CallbackTimeout:  envs.CallbackTimeout,
+		Providers:        envs.Providers,
+		PollBatchSize:    envs.PollBatchSize,
+		PollMaxInterval:  envs.PollMaxInterval,
+		InstanceID:       envs.InstanceID,
+		JobLease:         envs.JobLease,
+		QueryTimeout:     envs.QueryTimeout,
+		DBMaxConns:       envs.DBMaxConns,
+		DBMinConns:       envs.DBMinConns,
+		DBConnLifetime:   envs.DBConnLifetime,
+		DBConnIdleTime:   envs.DBConnIdleTime,
+		DBHealthCheck:    envs.DBHealthCheck,
+		DBStatementCache: envs.DBStatementCache,
 	}
 	if flags.Address == "" {
 		result.Address = envs.Address
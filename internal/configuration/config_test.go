@@ -2,6 +2,7 @@ package configuration
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -44,8 +45,38 @@ func TestConfigBothNotProvided(t *testing.T) {
 	flags := NewCliOptions()
 	config := NewConfig(flags, envs)
 	assert.Equal(t, &Config{
-		Address:     "localhost:8080",
-		DatabaseURI: "localhost:5432",
-		Accrual:     "localhost:8081",
+		Address:          "localhost:8080",
+		DatabaseURI:      "localhost:5432",
+		Accrual:          "localhost:8081",
+		PointsTTL:        12,
+		ExpirySweep:      time.Hour,
+		ReferralBonus:    100,
+		ReferralCap:      10,
+		Notifier:         "log",
+		NotifyFile:       "notifications.log",
+		SMTPAddress:      "localhost:1025",
+		SMTPFrom:         "gophermart@localhost",
+		Workers:          4,
+		QueueSize:        100,
+		RetryBase:        time.Second,
+		RetryMax:         5 * time.Minute,
+		RetryMaxAttempts: 10,
+		RetryMaxAge:      24 * time.Hour,
+		BreakerThreshold: 5,
+		BreakerTimeout:   30 * time.Second,
+		ShutdownTimeout:  30 * time.Second,
+		JobTimeout:       10 * time.Second,
+		AccrualTimeout:   5 * time.Second,
+		CallbackTimeout:  5 * time.Minute,
+		PollBatchSize:    100,
+		PollMaxInterval:  time.Minute,
+		JobLease:         30 * time.Second,
+		QueryTimeout:     5 * time.Second,
+		DBMaxConns:       10,
+		DBMinConns:       1,
+		DBConnLifetime:   time.Hour,
+		DBConnIdleTime:   30 * time.Minute,
+		DBHealthCheck:    time.Minute,
+		DBStatementCache: 512,
 	}, config)
 }
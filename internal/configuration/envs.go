@@ -1,14 +1,53 @@
 package configuration
 
 import (
+	"time"
+
 	"github.com/caarlos0/env/v6"
 	"github.com/nmramorov/gophemart/internal/logger"
 )
 
 type EnvConfig struct {
-	Address     string `env:"RUN_ADDRESS,required" envDefault:"localhost:8080"`
-	DatabaseURI string `env:"DATABASE_URI,required" envDefault:"localhost:5432"`
-	Accrual     string `env:"ACCRUAL_SYSTEM_ADDRESS,required" envDefault:"localhost:8081"`
+	Address          string        `env:"RUN_ADDRESS,required" envDefault:"localhost:8080"`
+	DatabaseURI      string        `env:"DATABASE_URI,required" envDefault:"localhost:5432"`
+	Accrual          string        `env:"ACCRUAL_SYSTEM_ADDRESS,required" envDefault:"localhost:8081"`
+	PointsTTL        int           `env:"POINTS_TTL_MONTHS" envDefault:"12"`
+	ExpirySweep      time.Duration `env:"EXPIRY_SWEEP_INTERVAL" envDefault:"1h"`
+	AdminToken       string        `env:"ADMIN_TOKEN"`
+	ReferralBonus    float64       `env:"REFERRAL_BONUS" envDefault:"100"`
+	ReferralCap      int           `env:"REFERRAL_CAP" envDefault:"10"`
+	Notifier         string        `env:"NOTIFIER" envDefault:"log"`
+	NotifyFile       string        `env:"NOTIFY_FILE" envDefault:"notifications.log"`
+	SMTPAddress      string        `env:"SMTP_ADDRESS" envDefault:"localhost:1025"`
+	SMTPFrom         string        `env:"SMTP_FROM" envDefault:"gophermart@localhost"`
+	SMTPUsername     string        `env:"SMTP_USERNAME"`
+	SMTPPassword     string        `env:"SMTP_PASSWORD"`
+	Workers          int           `env:"WORKERS" envDefault:"4"`
+	QueueSize        int           `env:"QUEUE_SIZE" envDefault:"100"`
+	AccrualRateLimit int           `env:"ACCRUAL_RATE_LIMIT" envDefault:"0"`
+	RetryBase        time.Duration `env:"RETRY_BASE" envDefault:"1s"`
+	RetryMax         time.Duration `env:"RETRY_MAX" envDefault:"5m"`
+	RetryMaxAttempts int           `env:"RETRY_MAX_ATTEMPTS" envDefault:"10"`
+	RetryMaxAge      time.Duration `env:"RETRY_MAX_AGE" envDefault:"24h"`
+	BreakerThreshold int           `env:"BREAKER_THRESHOLD" envDefault:"5"`
+	BreakerTimeout   time.Duration `env:"BREAKER_OPEN_TIMEOUT" envDefault:"30s"`
+	ShutdownTimeout  time.Duration `env:"SHUTDOWN_TIMEOUT" envDefault:"30s"`
+	JobTimeout       time.Duration `env:"JOB_TIMEOUT" envDefault:"10s"`
+	AccrualTimeout   time.Duration `env:"ACCRUAL_TIMEOUT" envDefault:"5s"`
+	CallbackSecret   string        `env:"CALLBACK_SECRET"`
+	CallbackTimeout  time.Duration `env:"CALLBACK_TIMEOUT" envDefault:"5m"`
+	Providers        Providers     `env:"ACCRUAL_PROVIDERS"`
+	PollBatchSize    int           `env:"POLL_BATCH_SIZE" envDefault:"100"`
+	PollMaxInterval  time.Duration `env:"POLL_MAX_INTERVAL" envDefault:"1m"`
+	InstanceID       string        `env:"INSTANCE_ID"`
+	JobLease         time.Duration `env:"JOB_LEASE" envDefault:"30s"`
+	QueryTimeout     time.Duration `env:"DB_QUERY_TIMEOUT" envDefault:"5s"`
+	DBMaxConns       int           `env:"DB_MAX_CONNS" envDefault:"10"`
+	DBMinConns       int           `env:"DB_MIN_CONNS" envDefault:"1"`
+	DBConnLifetime   time.Duration `env:"DB_MAX_CONN_LIFETIME" envDefault:"1h"`
+	DBConnIdleTime   time.Duration `env:"DB_MAX_CONN_IDLE_TIME" envDefault:"30m"`
+	DBHealthCheck    time.Duration `env:"DB_HEALTH_CHECK_PERIOD" envDefault:"1m"`
+	DBStatementCache int           `env:"DB_STATEMENT_CACHE" envDefault:"512"`
 }
 
 func NewEnvConfig() (*EnvConfig, error) {
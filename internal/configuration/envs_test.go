@@ -2,6 +2,7 @@ package configuration
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -11,4 +12,25 @@ func TestEnvConfig(t *testing.T) {
 	assert.Equal(t, testConfig.Address, "localhost:8080")
 	assert.Equal(t, testConfig.DatabaseURI, "localhost:5432")
 	assert.Equal(t, testConfig.Accrual, "localhost:8081")
+	assert.Equal(t, testConfig.PointsTTL, 12)
+	assert.Equal(t, testConfig.ExpirySweep, time.Hour)
+	assert.Equal(t, testConfig.ReferralBonus, float64(100))
+	assert.Equal(t, testConfig.ReferralCap, 10)
+}
+
+func TestEnvProviders(t *testing.T) {
+	t.Setenv("ACCRUAL_PROVIDERS", `[{"name": "cards", "url": "http://cards:8081", "rate_limit": 60, "token": "secret", "prefixes": ["4"]},
+		{"name": "legacy", "url": "http://legacy:8081", "ranges": [{"from": "1000", "to": "9999"}]}]`)
+	testConfig, err := NewEnvConfig()
+	assert.NoError(t, err)
+	assert.Len(t, testConfig.Providers, 2)
+	assert.Equal(t, &Provider{Name: "cards", URL: "http://cards:8081", RateLimit: 60, Token: "secret", Prefixes: []string{"4"}}, testConfig.Providers[0])
+	assert.Equal(t, []*Range{{From: "1000", To: "9999"}}, testConfig.Providers[1].Ranges)
+
+	t.Setenv("ACCRUAL_PROVIDERS", `[{"name": "cards", "url": "http://a"}, {"name": "cards", "url": "http://b"}]`)
+	_, err = NewEnvConfig()
+	assert.Error(t, err)
+	t.Setenv("ACCRUAL_PROVIDERS", `[{"name": "cards"}]`)
+	_, err = NewEnvConfig()
+	assert.Error(t, err)
 }
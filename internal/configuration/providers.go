@@ -0,0 +1,48 @@
+package configuration
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Range matches order numbers between From and To inclusive, compared as
+// numbers.
+type Range struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Provider is an accrual system serving the orders which start with one of
+// Prefixes or fall into one of Ranges.
+type Provider struct {
+	Name      string   `json:"name"`
+	URL       string   `json:"url"`
+	RateLimit int      `json:"rate_limit"`
+	Token     string   `json:"token"`
+	Username  string   `json:"username"`
+	Password  string   `json:"password"`
+	Prefixes  []string `json:"prefixes"`
+	Ranges    []*Range `json:"ranges"`
+}
+
+// Providers is read from ACCRUAL_PROVIDERS as a JSON list.
+type Providers []*Provider
+
+func (p *Providers) UnmarshalText(text []byte) error {
+	providers := []*Provider{}
+	if err := json.Unmarshal(text, &providers); err != nil {
+		return err
+	}
+	names := make(map[string]bool)
+	for _, provider := range providers {
+		if provider.Name == "" || provider.URL == "" {
+			return fmt.Errorf("accrual provider needs a name and an url")
+		}
+		if names[provider.Name] {
+			return fmt.Errorf("accrual provider %s is configured twice", provider.Name)
+		}
+		names[provider.Name] = true
+	}
+	*p = providers
+	return nil
+}
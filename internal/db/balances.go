@@ -0,0 +1,157 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/nmramorov/gophemart/internal/errors"
+	"github.com/nmramorov/gophemart/internal/logger"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+// GetUserBalance locks the balance of username. Inside WithTx the lock is
+// held until the transaction ends, so the balance can be read and updated
+// without losing a concurrent update.
+func (r *repos) GetUserBalance(ctx context.Context, username string) (*models.Balance, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	row := r.db.QueryRow(ctx, GetBalance, username)
+	logger.InfoLog.Printf("Getting balance for user %s", username)
+	foundBalance := &models.Balance{}
+	err := row.Scan(&foundBalance.User, &foundBalance.Current, &foundBalance.Withdrawn)
+	if err == pgx.ErrNoRows {
+		return foundBalance, nil
+	}
+	if err != nil {
+		logger.ErrorLog.Printf("error scanning balance from db: %e", err)
+		return nil, err
+	}
+	return foundBalance, nil
+}
+
+func (r *repos) SaveUserBalance(ctx context.Context, username string, newBalance *models.Balance) (*models.Balance, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	_, err := r.db.Exec(ctx, SaveBalance, username, newBalance.Current, newBalance.Withdrawn)
+	if err != nil {
+		logger.ErrorLog.Printf("error during saving balance for user %s: %e", username, err)
+		return nil, err
+	}
+	logger.InfoLog.Printf("Saved balance for %s, accrual is %f", username, newBalance.Current)
+	newBalance.User = username
+	return newBalance, nil
+}
+
+func (r *repos) UpdateUserBalance(ctx context.Context, username string, newBalance *models.Balance) (*models.Balance, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	_, err := r.db.Exec(ctx, UpdateBalance, newBalance.Current, newBalance.Withdrawn, username)
+	if err != nil {
+		logger.ErrorLog.Printf("error during updating balance: %e", err)
+		return nil, err
+	}
+	logger.InfoLog.Printf("Balance updated, Current: %f, Withdrawn: %f for user %s", newBalance.Current, newBalance.Withdrawn, username)
+	return newBalance, nil
+}
+
+// CreditBalance adds amount to the current balance of username, creating
+// the balance if needed.
+func (r *repos) CreditBalance(ctx context.Context, username string, amount float64) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	_, err := r.db.Exec(ctx, CreditBalance, username, amount)
+	if err != nil {
+		logger.ErrorLog.Printf("error during crediting balance of %s: %e", username, err)
+		return err
+	}
+	return nil
+}
+
+// DebitBalance takes amount off the current balance of username relative
+// to its stored value.
+func (r *repos) DebitBalance(ctx context.Context, username string, amount float64) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	_, err := r.db.Exec(ctx, DebitBalance, amount, username)
+	if err != nil {
+		logger.ErrorLog.Printf("error during debiting balance of %s: %e", username, err)
+		return err
+	}
+	return nil
+}
+
+func (r *repos) GetWithdrawals(ctx context.Context, username string) ([]*models.Withdrawal, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	rows, err := r.db.Query(ctx, GetWithdrawals, username)
+
+	if err != nil {
+		logger.ErrorLog.Printf("error during getting withdrawals from db: %e", err)
+		return nil, err
+	}
+	defer rows.Close()
+	if rows.Err() != nil {
+		logger.ErrorLog.Printf("error during getting withdrawals from db: %e", rows.Err())
+		return nil, rows.Err()
+	}
+	foundWithdrawals := []*models.Withdrawal{}
+	for rows.Next() {
+		var w models.Withdrawal
+		if err := rows.Scan(&w.User, &w.Order, &w.Sum, &w.ProcessedAt); err != nil {
+			logger.ErrorLog.Printf("error scanning withdrawal from db: %e", err)
+			return foundWithdrawals, err
+		}
+		foundWithdrawals = append(foundWithdrawals, &w)
+	}
+	if err = rows.Err(); err != nil {
+		return foundWithdrawals, err
+	}
+	return foundWithdrawals, nil
+}
+
+func (r *repos) SaveWithdrawal(ctx context.Context, withdrawal *models.Withdrawal) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	_, err := r.db.Exec(ctx, SaveWithdrawal, withdrawal.User, withdrawal.Order, withdrawal.Sum, withdrawal.ProcessedAt)
+	if err != nil {
+		logger.ErrorLog.Printf("error during saving withdrawal to db: %e", err)
+		return err
+	}
+	return nil
+}
+
+// Withdraw takes the sum off the balance, updates the consumed lots and
+// saves the withdrawal in a single round trip and a single transaction. A
+// balance lower than the sum changes nothing and returns
+// errors.ErrInsufficientBalance. The lots hold their new remaining points,
+// so read them with LockLots in the same unit of work.
+func (r *repos) Withdraw(ctx context.Context, withdrawal *models.Withdrawal, lots []*models.AccrualLot) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		logger.ErrorLog.Printf("error starting withdrawal of %s: %e", withdrawal.User, err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+	batch := &pgx.Batch{}
+	batch.Queue(WithdrawBalance, withdrawal.Sum, withdrawal.User)
+	for _, lot := range lots {
+		batch.Queue(UpdateLotRemaining, lot.Remaining, lot.ID)
+	}
+	batch.Queue(SaveWithdrawal, withdrawal.User, withdrawal.Order, withdrawal.Sum, withdrawal.ProcessedAt)
+	tags, err := sendBatch(ctx, tx, batch)
+	if err != nil {
+		logger.ErrorLog.Printf("error during withdrawal of %s: %e", withdrawal.User, err)
+		return err
+	}
+	if tags[0].RowsAffected() == 0 {
+		return errors.ErrInsufficientBalance
+	}
+	if err := tx.Commit(ctx); err != nil {
+		logger.ErrorLog.Printf("error committing withdrawal of %s: %e", withdrawal.User, err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/nmramorov/gophemart/internal/logger"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+func (r *repos) scanCampaigns(rows pgx.Rows) ([]*models.Campaign, error) {
+	defer rows.Close()
+	foundCampaigns := []*models.Campaign{}
+	for rows.Next() {
+		var cm models.Campaign
+		if err := rows.Scan(&cm.ID, &cm.Name, &cm.Kind, &cm.Value, &cm.FirstOrderOnly, &cm.PerUserCap, &cm.StartsAt, &cm.EndsAt); err != nil {
+			logger.ErrorLog.Printf("error scanning campaign from db: %e", err)
+			return foundCampaigns, err
+		}
+		foundCampaigns = append(foundCampaigns, &cm)
+	}
+	if err := rows.Err(); err != nil {
+		return foundCampaigns, err
+	}
+	return foundCampaigns, nil
+}
+
+func (r *repos) GetCampaigns(ctx context.Context) ([]*models.Campaign, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	rows, err := r.db.Query(ctx, GetCampaigns)
+	if err != nil {
+		logger.ErrorLog.Printf("error during getting campaigns from db: %e", err)
+		return nil, err
+	}
+	return r.scanCampaigns(rows)
+}
+
+func (r *repos) GetActiveCampaigns(ctx context.Context, now time.Time) ([]*models.Campaign, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	rows, err := r.db.Query(ctx, GetActiveCampaigns, now)
+	if err != nil {
+		logger.ErrorLog.Printf("error during getting active campaigns from db: %e", err)
+		return nil, err
+	}
+	return r.scanCampaigns(rows)
+}
+
+func (r *repos) GetCampaign(ctx context.Context, id int64) (*models.Campaign, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	cm := &models.Campaign{}
+	err := r.db.QueryRow(ctx, GetCampaign, id).
+		Scan(&cm.ID, &cm.Name, &cm.Kind, &cm.Value, &cm.FirstOrderOnly, &cm.PerUserCap, &cm.StartsAt, &cm.EndsAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		logger.ErrorLog.Printf("error scanning campaign %d from db: %e", id, err)
+		return nil, err
+	}
+	return cm, nil
+}
+
+func (r *repos) SaveCampaign(ctx context.Context, campaign *models.Campaign) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	err := r.db.QueryRow(ctx, SaveCampaign, campaign.Name, campaign.Kind, campaign.Value,
+		campaign.FirstOrderOnly, campaign.PerUserCap, campaign.StartsAt, campaign.EndsAt).Scan(&campaign.ID)
+	if err != nil {
+		logger.ErrorLog.Printf("error during saving campaign %s: %e", campaign.Name, err)
+		return err
+	}
+	return nil
+}
+
+func (r *repos) UpdateCampaign(ctx context.Context, campaign *models.Campaign) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	_, err := r.db.Exec(ctx, UpdateCampaign, campaign.Name, campaign.Kind, campaign.Value,
+		campaign.FirstOrderOnly, campaign.PerUserCap, campaign.StartsAt, campaign.EndsAt, campaign.ID)
+	if err != nil {
+		logger.ErrorLog.Printf("error during updating campaign %d: %e", campaign.ID, err)
+		return err
+	}
+	return nil
+}
+
+// DeleteCampaign hides the campaign from the admin API and stops its
+// bonuses. The row is kept, so the bonuses already credited stay in the
+// ledger with their campaign.
+func (r *repos) DeleteCampaign(ctx context.Context, id int64, now time.Time) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	_, err := r.db.Exec(ctx, DeleteCampaign, id, now)
+	if err != nil {
+		logger.ErrorLog.Printf("error during deleting campaign %d: %e", id, err)
+		return err
+	}
+	return nil
+}
+
+// SaveCampaignBonus stores the bonus unless the order already has one of
+// the campaign or the user already got perUserCap of them, zero meaning no
+// cap, and reports whether it did. Capped bonuses are numbered per user and
+// the numbers are unique, so concurrent orders never go over the cap.
+func (r *repos) SaveCampaignBonus(ctx context.Context, bonus *models.CampaignBonus, perUserCap int) (bool, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	result, err := r.db.Exec(ctx, SaveCampaignBonus, bonus.User, bonus.Order, bonus.CampaignID, bonus.Amount, bonus.CreditedAt, perUserCap)
+	if err != nil {
+		logger.ErrorLog.Printf("error during saving bonus of campaign %d for order %s: %e", bonus.CampaignID, bonus.Order, err)
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+func (r *repos) GetOrderBonuses(ctx context.Context, number string) ([]*models.CampaignBonus, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	rows, err := r.db.Query(ctx, GetOrderBonuses, number)
+	if err != nil {
+		logger.ErrorLog.Printf("error during getting bonuses of order %s: %e", number, err)
+		return nil, err
+	}
+	defer rows.Close()
+	foundBonuses := []*models.CampaignBonus{}
+	for rows.Next() {
+		var b models.CampaignBonus
+		if err := rows.Scan(&b.User, &b.Order, &b.CampaignID, &b.Campaign, &b.Amount, &b.CreditedAt); err != nil {
+			logger.ErrorLog.Printf("error scanning bonus from db: %e", err)
+			return foundBonuses, err
+		}
+		foundBonuses = append(foundBonuses, &b)
+	}
+	if err := rows.Err(); err != nil {
+		return foundBonuses, err
+	}
+	return foundBonuses, nil
+}
@@ -0,0 +1,291 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/nmramorov/gophemart/internal/errors"
+	"github.com/nmramorov/gophemart/internal/logger"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+// EnqueueJob queues a job for the order, due for its first check at
+// nextRunAt.
+func (r *repos) EnqueueJob(ctx context.Context, number string, username string, nextRunAt time.Time, now time.Time) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	_, err := r.db.Exec(ctx, EnqueueJob, number, username, nextRunAt, now)
+	if err != nil {
+		logger.ErrorLog.Printf("error during enqueueing job for order %s: %e", number, err)
+		return err
+	}
+	return nil
+}
+
+// ClaimJobs leases up to limit due jobs to owner until leaseUntil. Jobs
+// whose lease expired, because their owner crashed or hangs, are due again.
+// Only jobs of orders routed to one of providers are claimed, or with except
+// the jobs of orders routed to none of them.
+func (r *repos) ClaimJobs(ctx context.Context, owner string, now time.Time, leaseUntil time.Time, limit int, providers []string, except bool) ([]*models.AccrualJob, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	if providers == nil {
+		providers = []string{}
+	}
+	rows, err := r.db.Query(ctx, ClaimJobs, owner, now, leaseUntil, limit, providers, except)
+	if err != nil {
+		logger.ErrorLog.Printf("error during claiming jobs: %e", err)
+		return nil, err
+	}
+	defer rows.Close()
+	claimed := []*models.AccrualJob{}
+	for rows.Next() {
+		var j models.AccrualJob
+		if err := rows.Scan(&j.Order, &j.User, &j.Status, &j.Attempts, &j.Failures, &j.NextRunAt, &j.LastError, &j.Owner, &j.LeaseUntil,
+			&j.StatusCode, &j.CheckedAt, &j.CreatedAt, &j.UpdatedAt); err != nil {
+			logger.ErrorLog.Printf("error scanning claimed job: %e", err)
+			return claimed, err
+		}
+		claimed = append(claimed, &j)
+	}
+	if err := rows.Err(); err != nil {
+		return claimed, err
+	}
+	return claimed, nil
+}
+
+// RescheduleJob hands a job leased to owner back to the queue. It fails
+// with ErrLeaseLost once the lease expired or another instance claimed the
+// job, so a stalled worker never overwrites the job of its new owner.
+func (r *repos) RescheduleJob(ctx context.Context, number string, owner string, nextRunAt time.Time, lastError string, now time.Time) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	result, err := r.db.Exec(ctx, RescheduleJob, nextRunAt, lastError, now, number, owner)
+	if err != nil {
+		logger.ErrorLog.Printf("error during rescheduling job for order %s: %e", number, err)
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return errors.ErrLeaseLost
+	}
+	return nil
+}
+
+// RetryJob is fenced by the lease of owner like RescheduleJob.
+func (r *repos) RetryJob(ctx context.Context, number string, owner string, nextRunAt time.Time, lastError string, statusCode int, now time.Time) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	result, err := r.db.Exec(ctx, RetryJob, nextRunAt, lastError, statusCode, now, number, owner)
+	if err != nil {
+		logger.ErrorLog.Printf("error during scheduling retry of job for order %s: %e", number, err)
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return errors.ErrLeaseLost
+	}
+	return nil
+}
+
+// FailJob is fenced by the lease of owner like RescheduleJob.
+func (r *repos) FailJob(ctx context.Context, number string, owner string, lastError string, statusCode int, now time.Time) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	result, err := r.db.Exec(ctx, FailJob, lastError, statusCode, now, number, owner)
+	if err != nil {
+		logger.ErrorLog.Printf("error during failing job for order %s: %e", number, err)
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return errors.ErrLeaseLost
+	}
+	return nil
+}
+
+func (r *repos) RequeueJobs(ctx context.Context, owner string, now time.Time) (int64, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	result, err := r.db.Exec(ctx, RequeueJobs, now, owner)
+	if err != nil {
+		logger.ErrorLog.Printf("error during requeueing unfinished jobs: %e", err)
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+func (r *repos) RenewLeases(ctx context.Context, owner string, leaseUntil time.Time) (int64, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	result, err := r.db.Exec(ctx, RenewLeases, leaseUntil, owner)
+	if err != nil {
+		logger.ErrorLog.Printf("error during renewing leases of %s: %e", owner, err)
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+func (r *repos) SaveJobAttempt(ctx context.Context, attempt *models.JobAttempt) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	_, err := r.db.Exec(ctx, SaveJobAttempt, attempt.Order, attempt.Attempt, attempt.StatusCode,
+		attempt.Error, attempt.ResponseBody, attempt.AttemptedAt)
+	if err != nil {
+		logger.ErrorLog.Printf("error during saving attempt of job for order %s: %e", attempt.Order, err)
+		return err
+	}
+	return nil
+}
+
+func (r *repos) GetJobAttempts(ctx context.Context, number string) ([]*models.JobAttempt, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	rows, err := r.db.Query(ctx, GetJobAttempts, number)
+	if err != nil {
+		logger.ErrorLog.Printf("error during getting attempts of job for order %s: %e", number, err)
+		return nil, err
+	}
+	defer rows.Close()
+	attempts := []*models.JobAttempt{}
+	for rows.Next() {
+		var a models.JobAttempt
+		if err := rows.Scan(&a.Order, &a.Attempt, &a.StatusCode, &a.Error, &a.ResponseBody, &a.AttemptedAt); err != nil {
+			logger.ErrorLog.Printf("error scanning job attempt from db: %e", err)
+			return attempts, err
+		}
+		attempts = append(attempts, &a)
+	}
+	if err := rows.Err(); err != nil {
+		return attempts, err
+	}
+	return attempts, nil
+}
+
+func (r *repos) SaveDeadLetter(ctx context.Context, letter *models.DeadLetter) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	_, err := r.db.Exec(ctx, SaveDeadLetter, letter.Order, letter.User, letter.LastError, letter.StatusCode,
+		letter.ResponseBody, letter.Attempts, letter.Failures, letter.FailedAt)
+	if err != nil {
+		logger.ErrorLog.Printf("error during saving dead letter for order %s: %e", letter.Order, err)
+		return err
+	}
+	return nil
+}
+
+func (r *repos) GetDeadLetters(ctx context.Context) ([]*models.DeadLetter, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	rows, err := r.db.Query(ctx, GetDeadLetters)
+	if err != nil {
+		logger.ErrorLog.Printf("error during getting dead letters from db: %e", err)
+		return nil, err
+	}
+	defer rows.Close()
+	letters := []*models.DeadLetter{}
+	for rows.Next() {
+		var l models.DeadLetter
+		if err := rows.Scan(&l.Order, &l.User, &l.LastError, &l.StatusCode, &l.ResponseBody, &l.Attempts, &l.Failures, &l.FailedAt); err != nil {
+			logger.ErrorLog.Printf("error scanning dead letter from db: %e", err)
+			return letters, err
+		}
+		letters = append(letters, &l)
+	}
+	if err := rows.Err(); err != nil {
+		return letters, err
+	}
+	return letters, nil
+}
+
+func (r *repos) GetDeadLetter(ctx context.Context, number string) (*models.DeadLetter, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	l := &models.DeadLetter{}
+	err := r.db.QueryRow(ctx, GetDeadLetter, number).
+		Scan(&l.Order, &l.User, &l.LastError, &l.StatusCode, &l.ResponseBody, &l.Attempts, &l.Failures, &l.FailedAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		logger.ErrorLog.Printf("error scanning dead letter for order %s from db: %e", number, err)
+		return nil, err
+	}
+	return l, nil
+}
+
+func (r *repos) DeleteDeadLetter(ctx context.Context, number string) (bool, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	result, err := r.db.Exec(ctx, DeleteDeadLetter, number)
+	if err != nil {
+		logger.ErrorLog.Printf("error during deleting dead letter for order %s: %e", number, err)
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+func (r *repos) ResetJob(ctx context.Context, number string, username string, now time.Time) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	_, err := r.db.Exec(ctx, ResetJob, number, username, now)
+	if err != nil {
+		logger.ErrorLog.Printf("error during resetting job for order %s: %e", number, err)
+		return err
+	}
+	return nil
+}
+
+func (r *repos) DeleteJob(ctx context.Context, number string) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	_, err := r.db.Exec(ctx, DeleteJob, number)
+	if err != nil {
+		logger.ErrorLog.Printf("error during deleting job for order %s: %e", number, err)
+		return err
+	}
+	return nil
+}
+
+func (r *repos) GetJob(ctx context.Context, number string) (*models.AccrualJob, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	j := &models.AccrualJob{}
+	err := r.db.QueryRow(ctx, GetJob, number).
+		Scan(&j.Order, &j.User, &j.Status, &j.Attempts, &j.Failures, &j.NextRunAt, &j.LastError, &j.Owner, &j.LeaseUntil,
+			&j.StatusCode, &j.CheckedAt, &j.CreatedAt, &j.UpdatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		logger.ErrorLog.Printf("error scanning job for order %s from db: %e", number, err)
+		return nil, err
+	}
+	return j, nil
+}
+
+// GetJobs lists the unfinished and dead jobs in the given state, all of
+// them for an empty state, next attempt first.
+func (r *repos) GetJobs(ctx context.Context, state string, limit int) ([]*models.AccrualJob, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	rows, err := r.db.Query(ctx, GetJobs, state, limit)
+	if err != nil {
+		logger.ErrorLog.Printf("error during getting jobs from db: %e", err)
+		return nil, err
+	}
+	defer rows.Close()
+	jobs := []*models.AccrualJob{}
+	for rows.Next() {
+		var j models.AccrualJob
+		if err := rows.Scan(&j.Order, &j.User, &j.Status, &j.Attempts, &j.Failures, &j.NextRunAt, &j.LastError, &j.Owner, &j.LeaseUntil,
+			&j.StatusCode, &j.CheckedAt, &j.CreatedAt, &j.UpdatedAt, &j.State); err != nil {
+			logger.ErrorLog.Printf("error scanning job from db: %e", err)
+			return jobs, err
+		}
+		jobs = append(jobs, &j)
+	}
+	if err := rows.Err(); err != nil {
+		return jobs, err
+	}
+	return jobs, nil
+}
@@ -0,0 +1,141 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/nmramorov/gophemart/internal/logger"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+func (r *repos) SaveAccrualLot(ctx context.Context, lot *models.AccrualLot) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	_, err := r.db.Exec(ctx, SaveAccrualLot, lot.User, lot.Order, lot.Amount, lot.Base, lot.Remaining, lot.AccruedAt, lot.ExpiresAt)
+	if err != nil {
+		logger.ErrorLog.Printf("error during saving accrual lot for order %s: %e", lot.Order, err)
+		return err
+	}
+	return nil
+}
+
+func (r *repos) scanLots(rows pgx.Rows) ([]*models.AccrualLot, error) {
+	defer rows.Close()
+	foundLots := []*models.AccrualLot{}
+	for rows.Next() {
+		var l models.AccrualLot
+		if err := rows.Scan(&l.ID, &l.User, &l.Order, &l.Amount, &l.Base, &l.Remaining, &l.AccruedAt, &l.ExpiresAt); err != nil {
+			logger.ErrorLog.Printf("error scanning accrual lot from db: %e", err)
+			return foundLots, err
+		}
+		foundLots = append(foundLots, &l)
+	}
+	if err := rows.Err(); err != nil {
+		return foundLots, err
+	}
+	return foundLots, nil
+}
+
+func (r *repos) GetActiveLots(ctx context.Context, username string, now time.Time) ([]*models.AccrualLot, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	rows, err := r.db.Query(ctx, GetActiveLots, username, now)
+	if err != nil {
+		logger.ErrorLog.Printf("error during getting active lots for %s from db: %e", username, err)
+		return nil, err
+	}
+	return r.scanLots(rows)
+}
+
+// LockLots locks every lot of username with points left, expired ones
+// included, until the end of the unit of work, so their remaining points can
+// be written back by Withdraw without losing a concurrent withdrawal or
+// expiry.
+func (r *repos) LockLots(ctx context.Context, username string) ([]*models.AccrualLot, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	rows, err := r.db.Query(ctx, LockLots, username)
+	if err != nil {
+		logger.ErrorLog.Printf("error during locking active lots of %s: %e", username, err)
+		return nil, err
+	}
+	return r.scanLots(rows)
+}
+
+func (r *repos) GetExpiredLots(ctx context.Context, now time.Time) ([]*models.AccrualLot, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	rows, err := r.db.Query(ctx, GetExpiredLots, now)
+	if err != nil {
+		logger.ErrorLog.Printf("error during getting expired lots from db: %e", err)
+		return nil, err
+	}
+	return r.scanLots(rows)
+}
+
+// ExpireLot zeroes the remaining points of the lot and returns how many
+// there were. The lot is locked first, so a lot already expired by another
+// sweep or used up by a withdrawal in the meantime returns zero.
+func (r *repos) ExpireLot(ctx context.Context, id int64) (float64, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	var expired float64
+	err := r.db.QueryRow(ctx, ExpireLot, id).Scan(&expired)
+	if err == pgx.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		logger.ErrorLog.Printf("error during expiring accrual lot %d: %e", id, err)
+		return 0, err
+	}
+	return expired, nil
+}
+
+func (r *repos) SaveExpiration(ctx context.Context, expiration *models.Expiration) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	_, err := r.db.Exec(ctx, SaveExpiration, expiration.User, expiration.Order, expiration.Sum, expiration.ExpiredAt)
+	if err != nil {
+		logger.ErrorLog.Printf("error during saving expiration for order %s: %e", expiration.Order, err)
+		return err
+	}
+	return nil
+}
+
+func (r *repos) GetTiers(ctx context.Context) ([]*models.Tier, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	rows, err := r.db.Query(ctx, GetTiers)
+	if err != nil {
+		logger.ErrorLog.Printf("error during getting tiers from db: %e", err)
+		return nil, err
+	}
+	defer rows.Close()
+	foundTiers := []*models.Tier{}
+	for rows.Next() {
+		var t models.Tier
+		if err := rows.Scan(&t.Name, &t.MinAccrual, &t.Multiplier); err != nil {
+			logger.ErrorLog.Printf("error scanning tier from db: %e", err)
+			return foundTiers, err
+		}
+		foundTiers = append(foundTiers, &t)
+	}
+	if err := rows.Err(); err != nil {
+		return foundTiers, err
+	}
+	return foundTiers, nil
+}
+
+func (r *repos) GetAccruedSince(ctx context.Context, username string, since time.Time) (float64, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	var accrued float64
+	err := r.db.QueryRow(ctx, GetAccruedSince, username, since).Scan(&accrued)
+	if err != nil {
+		logger.ErrorLog.Printf("error during getting accrued sum for %s: %e", username, err)
+		return 0, err
+	}
+	return accrued, nil
+}
@@ -0,0 +1,225 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/nmramorov/gophemart/internal/errors"
+	"github.com/nmramorov/gophemart/internal/logger"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+func (r *repos) GetOrder(ctx context.Context, username string, number string) (*models.Order, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	row := r.db.QueryRow(ctx, GetOrder, username, number)
+	foundOrder := &models.Order{}
+	err := row.Scan(&foundOrder.Username, &foundOrder.Number, &foundOrder.Status, &foundOrder.Accrual, &foundOrder.UploadedAt, &foundOrder.Provider)
+	if err == pgx.ErrNoRows {
+		logger.ErrorLog.Printf("No rows found for order %s and user %s", number, username)
+		return nil, nil
+	}
+	if err != nil {
+		logger.ErrorLog.Printf("error scanning single order from db: %e", err)
+		return nil, err
+	}
+	return foundOrder, nil
+}
+
+func (r *repos) SaveOrder(ctx context.Context, order *models.Order) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	_, err := r.db.Exec(ctx, SaveOrder, order.Username, order.Number, order.Status, order.Accrual, order.UploadedAt)
+	if err != nil {
+		logger.ErrorLog.Printf("error during saving order %s to db: %e", order.Number, err)
+		return err
+	}
+	return nil
+}
+
+func (r *repos) GetOrders(ctx context.Context, username string) ([]*models.Order, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	rows, err := r.db.Query(ctx, GetOrders, username)
+	if err != nil {
+		logger.ErrorLog.Printf("error during getting orders from db: %e", err)
+		return nil, err
+	}
+	defer rows.Close()
+	if rows.Err() != nil {
+		logger.ErrorLog.Printf("error during getting orders from db: %e", rows.Err())
+		return nil, rows.Err()
+	}
+	foundOrders := []*models.Order{}
+	for rows.Next() {
+		var o models.Order
+		if err = rows.Scan(&o.Username, &o.Number, &o.Status, &o.Accrual, &o.UploadedAt, &o.Provider, &o.CheckedAt, &o.NextCheck); err != nil {
+			logger.ErrorLog.Printf("error scanning order for %s from db: %e", username, err)
+			return foundOrders, err
+		}
+		foundOrders = append(foundOrders, &o)
+	}
+	return foundOrders, nil
+}
+
+func (r *repos) GetAllOrders(ctx context.Context) ([]*models.Order, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	rows, err := r.db.Query(ctx, GetAllOrders)
+
+	if err != nil {
+		logger.ErrorLog.Printf("error during getting all orders from db: %e", err)
+		return nil, err
+	}
+	defer rows.Close()
+	if rows.Err() != nil {
+		logger.ErrorLog.Printf("error during getting all orders from db: %e", rows.Err())
+		return nil, rows.Err()
+	}
+	foundOrders := []*models.Order{}
+	for rows.Next() {
+		var o models.Order
+		if err = rows.Scan(&o.Username, &o.Number, &o.Status, &o.Accrual, &o.UploadedAt, &o.Provider); err != nil {
+			logger.ErrorLog.Printf("error scanning order among orders from db: %e", err)
+			logger.ErrorLog.Println(foundOrders)
+			return foundOrders, nil
+		}
+		foundOrders = append(foundOrders, &o)
+	}
+	return foundOrders, nil
+}
+
+// UpdateOrder moves the order to status. Transitions not allowed by the
+// status table, e.g. a PROCESSED order going back to PROCESSING, change
+// nothing and return errors.ErrOrderTransition.
+func (r *repos) UpdateOrder(ctx context.Context, username string, number string, status models.OrderStatus, accrual float64) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	if !status.Valid() {
+		logger.ErrorLog.Printf("rejecting unknown status %q of order %s", status, number)
+		return errors.ErrUnknownStatus
+	}
+	result, err := r.db.Exec(ctx, fmt.Sprintf(UpdateOrder, statusIn(status.Sources())), status, accrual, username, number)
+	if err != nil {
+		logger.ErrorLog.Printf("error during updating order: %e", err)
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		logger.ErrorLog.Printf("order %s can not become %s", number, status)
+		return errors.ErrOrderTransition
+	}
+	return nil
+}
+
+// CreditOrder moves the order to its final status, credits the accrual to
+// the balance, saves the lot and finishes the job in one transaction. Only
+// the first credit of an order is applied, later ones, from a duplicate job,
+// a redelivered callback or another instance, change nothing and report
+// false.
+func (r *repos) CreditOrder(ctx context.Context, credit *models.Credit) (bool, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	if !credit.Status.Final() {
+		logger.ErrorLog.Printf("rejecting credit of order %s with status %q", credit.Order, credit.Status)
+		return false, errors.ErrOrderTransition
+	}
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		logger.ErrorLog.Printf("error starting credit of order %s: %e", credit.Order, err)
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+	result, err := tx.Exec(ctx, fmt.Sprintf(CreditOrder, statusIn(credit.Status.Sources())), credit.Status, credit.Accrual, credit.User, credit.Order)
+	if err != nil {
+		logger.ErrorLog.Printf("error during crediting order %s: %e", credit.Order, err)
+		return false, err
+	}
+	if result.RowsAffected() == 0 {
+		return false, nil
+	}
+	if credit.Accrual > 0 {
+		if _, err := tx.Exec(ctx, CreditBalance, credit.User, credit.Accrual); err != nil {
+			logger.ErrorLog.Printf("error during crediting balance of %s: %e", credit.User, err)
+			return false, err
+		}
+	}
+	if lot := credit.Lot; lot != nil {
+		if _, err := tx.Exec(ctx, SaveAccrualLot, lot.User, lot.Order, lot.Amount, lot.Base, lot.Remaining, lot.AccruedAt, lot.ExpiresAt); err != nil {
+			logger.ErrorLog.Printf("error during saving lot of order %s: %e", credit.Order, err)
+			return false, err
+		}
+	}
+	if _, err := tx.Exec(ctx, CreditJob, credit.StatusCode, credit.CreditedAt, credit.Order); err != nil {
+		logger.ErrorLog.Printf("error during finishing job for order %s: %e", credit.Order, err)
+		return false, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		logger.ErrorLog.Printf("error committing credit of order %s: %e", credit.Order, err)
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *repos) SetOrderProvider(ctx context.Context, number string, provider string) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	_, err := r.db.Exec(ctx, SetOrderProvider, provider, number)
+	if err != nil {
+		logger.ErrorLog.Printf("error during setting provider of order %s: %e", number, err)
+		return err
+	}
+	return nil
+}
+
+// UpdateChecks stores the polled orders and puts their jobs back into the
+// queue with a single statement. Only transitions allowed by the status
+// table are stored, so orders finished in the meantime, for example by a
+// callback, are left alone. Checks of jobs claimed by another instance than
+// owner are dropped as well; the number of checks stored is returned.
+func (r *repos) UpdateChecks(ctx context.Context, owner string, checks []*models.OrderCheck, now time.Time) (int64, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	if len(checks) == 0 {
+		return 0, nil
+	}
+	rows := make([]string, 0, len(checks))
+	args := make([]interface{}, 0, 2+7*len(checks))
+	args = append(args, now, owner)
+	for _, check := range checks {
+		n := len(args)
+		rows = append(rows, fmt.Sprintf(UpdateChecksRow, n+1, n+2, n+3, n+4, n+5, n+6, n+7))
+		args = append(args, check.Order, check.User, check.Status, check.Accrual, check.StatusCode, check.CheckedAt, check.NextCheckAt)
+	}
+	result, err := r.db.Exec(ctx, fmt.Sprintf(UpdateChecks, strings.Join(rows, ", "), transitionsIn()), args...)
+	if err != nil {
+		logger.ErrorLog.Printf("error during updating %d checked orders: %e", len(checks), err)
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+// statusIn lists statuses for the IN guard of a query. The statuses come
+// from the transition table, never from a request.
+func statusIn(statuses []models.OrderStatus) string {
+	if len(statuses) == 0 {
+		return "NULL"
+	}
+	quoted := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		quoted = append(quoted, "'"+string(status)+"'")
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// transitionsIn lists every allowed (from, to) pair of statuses.
+func transitionsIn() string {
+	pairs := []string{}
+	for _, pair := range models.OrderTransitions() {
+		pairs = append(pairs, "("+statusIn(pair[:])+")")
+	}
+	return strings.Join(pairs, ", ")
+}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"expvar"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	config "github.com/nmramorov/gophemart/internal/configuration"
+	"github.com/nmramorov/gophemart/internal/logger"
+	"github.com/nmramorov/gophemart/internal/metrics"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+// NewPool opens the connection pool with the configured limits. Prepared
+// statements are cached per connection, a zero cache size disables caching
+// and describes every query before executing it.
+func NewPool(config *config.Config) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(config.DatabaseURI)
+	if err != nil {
+		return nil, err
+	}
+	if config.DBMaxConns > 0 {
+		poolConfig.MaxConns = int32(config.DBMaxConns)
+	}
+	if config.DBMinConns > 0 && config.DBMinConns <= int(poolConfig.MaxConns) {
+		poolConfig.MinConns = int32(config.DBMinConns)
+	}
+	if config.DBConnLifetime > 0 {
+		poolConfig.MaxConnLifetime = config.DBConnLifetime
+	}
+	if config.DBConnIdleTime > 0 {
+		poolConfig.MaxConnIdleTime = config.DBConnIdleTime
+	}
+	if config.DBHealthCheck > 0 {
+		poolConfig.HealthCheckPeriod = config.DBHealthCheck
+	}
+	if config.DBStatementCache > 0 {
+		poolConfig.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeCacheStatement
+		poolConfig.ConnConfig.StatementCacheCapacity = config.DBStatementCache
+	} else {
+		poolConfig.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeDescribeExec
+	}
+	logger.InfoLog.Printf("Database pool: %d to %d connections, lifetime %s, statement cache %d",
+		poolConfig.MinConns, poolConfig.MaxConns, poolConfig.MaxConnLifetime, config.DBStatementCache)
+	return pgxpool.NewWithConfig(context.Background(), poolConfig)
+}
+
+// Stats returns a snapshot of the connection pool.
+func (c *DBCursor) Stats() *models.PoolStats {
+	stat := c.Pool.Stat()
+	return &models.PoolStats{
+		TotalConns:      stat.TotalConns(),
+		IdleConns:       stat.IdleConns(),
+		AcquiredConns:   stat.AcquiredConns(),
+		MaxConns:        stat.MaxConns(),
+		AcquireCount:    stat.AcquireCount(),
+		AcquireWait:     stat.AcquireDuration().Seconds(),
+		EmptyAcquires:   stat.EmptyAcquireCount(),
+		CanceledAcquire: stat.CanceledAcquireCount(),
+	}
+}
+
+// publishStats serves the pool statistics with the other metrics.
+func (c *DBCursor) publishStats() {
+	metrics.Database.Set("pool", expvar.Func(func() interface{} { return c.Stats() }))
+}
+
+type batchSender interface {
+	SendBatch(context.Context, *pgx.Batch) pgx.BatchResults
+}
+
+// sendBatch runs the queued statements of batch in a single round trip on
+// the pool or on a transaction and returns their command tags. Outside of a
+// transaction the batch runs in an implicit one.
+func sendBatch(ctx context.Context, sender batchSender, batch *pgx.Batch) ([]pgconn.CommandTag, error) {
+	results := sender.SendBatch(ctx, batch)
+	tags := make([]pgconn.CommandTag, 0, batch.Len())
+	for i := 0; i < batch.Len(); i++ {
+		tag, err := results.Exec()
+		if err != nil {
+			results.Close()
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, results.Close()
+}
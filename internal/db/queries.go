@@ -5,15 +5,161 @@ const (
 	GetUserInfo           = `SELECT * FROM userinfo WHERE username=$1;`
 	GetOrder              = `SELECT * FROM orders WHERE username=$1 AND _number=$2;`
 	SaveOrder             = `INSERT INTO orders VALUES ($1, $2, $3, $4, $5);`
-	GetOrders             = `SELECT * FROM orders WHERE username=$1;`
+	GetOrders             = `SELECT o.username, o._number, o._status, o.accrual, o.uploaded_at, o.provider, j.last_checked_at, CASE WHEN j._status IN ('QUEUED', 'RUNNING') THEN j.next_run_at END FROM orders o LEFT JOIN accrual_jobs j ON j._order=o._number WHERE o.username=$1;`
 	GetSessionUser        = `SELECT username FROM _sessions WHERE token=$1;`
-	GetBalance            = `SELECT * FROM balances WHERE username=$1;`
+	GetBalance            = `SELECT * FROM balances WHERE username=$1 FOR UPDATE;`
 	UpdateBalance  string = `UPDATE balances SET _current=$1, withdrawn=$2 WHERE username=$3;`
 	GetWithdrawals        = `SELECT * FROM withdrawal WHERE username=$1;`
 	SaveWithdrawal        = `INSERT INTO withdrawal VALUES ($1, $2, $3, $4);`
-	UpdateOrder           = `UPDATE orders SET _status=$1, accrual=$2 WHERE username=$3 AND _number=$4;`
+	UpdateOrder           = `UPDATE orders SET _status=$1, accrual=$2 WHERE username=$3 AND _number=$4 AND _status IN (%s);`
 	GetSession            = `SELECT * FROM _sessions WHERE token=$1;`
 	GetAllOrders          = `SELECT * FROM orders;`
 	SaveUserInfo          = `INSERT INTO userinfo VALUES ($1, $2);`
 	SaveBalance           = `INSERT INTO balances VALUES ($1, $2, $3);`
 )
+
+const (
+	SaveAccrualLot     = `INSERT INTO accrual_lots (username, _order, amount, base_amount, remaining, accrued_at, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (_order) DO NOTHING;`
+	GetActiveLots      = `SELECT id, username, _order, amount, base_amount, remaining, accrued_at, expires_at FROM accrual_lots WHERE username=$1 AND remaining > 0 AND expires_at > $2 ORDER BY accrued_at, id;`
+	LockLots           = `SELECT id, username, _order, amount, base_amount, remaining, accrued_at, expires_at FROM accrual_lots WHERE username=$1 AND remaining > 0 ORDER BY accrued_at, id FOR UPDATE;`
+	UpdateLotRemaining = `UPDATE accrual_lots SET remaining=$1 WHERE id=$2;`
+	GetExpiredLots     = `SELECT id, username, _order, amount, base_amount, remaining, accrued_at, expires_at FROM accrual_lots WHERE remaining > 0 AND expires_at <= $1 ORDER BY expires_at, id;`
+	SaveExpiration     = `INSERT INTO expirations VALUES ($1, $2, $3, $4);`
+	ExpireLot          = `UPDATE accrual_lots SET remaining=0
+		FROM (SELECT id, remaining FROM accrual_lots WHERE id=$1 AND remaining > 0 FOR UPDATE) AS expired
+		WHERE accrual_lots.id=expired.id RETURNING expired.remaining;`
+	DebitBalance = `UPDATE balances SET _current=_current-$1 WHERE username=$2;`
+)
+
+const (
+	GetTiers        = `SELECT _name, min_accrual, multiplier FROM tiers ORDER BY min_accrual;`
+	GetAccruedSince = `SELECT COALESCE(SUM(base_amount), 0) FROM accrual_lots WHERE username=$1 AND accrued_at > $2;`
+)
+
+const (
+	GetCampaigns       = `SELECT id, _name, kind, _value, first_order_only, per_user_cap, starts_at, ends_at FROM campaigns WHERE deleted_at IS NULL ORDER BY id;`
+	GetActiveCampaigns = `SELECT id, _name, kind, _value, first_order_only, per_user_cap, starts_at, ends_at FROM campaigns WHERE starts_at <= $1 AND ends_at > $1 AND deleted_at IS NULL ORDER BY id;`
+	GetCampaign        = `SELECT id, _name, kind, _value, first_order_only, per_user_cap, starts_at, ends_at FROM campaigns WHERE id=$1 AND deleted_at IS NULL;`
+	SaveCampaign       = `INSERT INTO campaigns (_name, kind, _value, first_order_only, per_user_cap, starts_at, ends_at) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id;`
+	UpdateCampaign     = `UPDATE campaigns SET _name=$1, kind=$2, _value=$3, first_order_only=$4, per_user_cap=$5, starts_at=$6, ends_at=$7 WHERE id=$8 AND deleted_at IS NULL;`
+	DeleteCampaign     = `UPDATE campaigns SET deleted_at=$2 WHERE id=$1 AND deleted_at IS NULL;`
+	SaveCampaignBonus  = `INSERT INTO campaign_bonuses (username, _order, campaign_id, amount, credited_at, seq)
+		SELECT $1::VARCHAR, $2::VARCHAR, $3::BIGINT, $4::FLOAT, $5::TIMESTAMP, CASE WHEN $6::INTEGER > 0 THEN COUNT(*) + 1 END
+		FROM campaign_bonuses WHERE username=$1 AND campaign_id=$3
+		HAVING $6::INTEGER = 0 OR COUNT(*) < $6::INTEGER
+		ON CONFLICT DO NOTHING;`
+	GetOrderBonuses = `SELECT b.username, b._order, b.campaign_id, c._name, b.amount, b.credited_at FROM campaign_bonuses b JOIN campaigns c ON c.id = b.campaign_id WHERE b._order=$1 ORDER BY b.campaign_id;`
+)
+
+const (
+	SaveReferralCode  = `INSERT INTO referral_codes VALUES ($1, $2);`
+	GetReferralCode   = `SELECT code FROM referral_codes WHERE username=$1;`
+	GetReferrerByCode = `SELECT username FROM referral_codes WHERE code=$1;`
+	LockReferrer      = `SELECT username FROM referral_codes WHERE username=$1 FOR UPDATE;`
+	SaveReferral      = `INSERT INTO referrals VALUES ($1, $2, $3, $4, $5);`
+	CountReferrals    = `SELECT COUNT(*) FROM referrals WHERE referrer=$1 AND _status <> 'REJECTED';`
+	GetReferrals      = `SELECT referrer, referee, _status, created_at, rewarded_at FROM referrals WHERE referrer=$1 ORDER BY created_at;`
+	GetReferral       = `SELECT referrer, referee, _status, created_at, rewarded_at FROM referrals WHERE referee=$1;`
+	CompleteReferral  = `UPDATE referrals SET _status='REWARDED', rewarded_at=$1 WHERE referee=$2 AND _status='PENDING';`
+)
+
+const (
+	GetNotificationPreferences  = `SELECT username, email, orders, withdrawals, logins FROM notification_preferences WHERE username=$1;`
+	SaveNotificationPreferences = `INSERT INTO notification_preferences VALUES ($1, $2, $3, $4, $5) ON CONFLICT (username) DO UPDATE SET email=$2, orders=$3, withdrawals=$4, logins=$5;`
+	HasDevice                   = `SELECT EXISTS (SELECT 1 FROM devices WHERE username=$1 AND fingerprint=$2);`
+	SaveDevice                  = `INSERT INTO devices VALUES ($1, $2, $3) ON CONFLICT (username, fingerprint) DO UPDATE SET seen_at=$3;`
+)
+
+const (
+	EnqueueJob = `INSERT INTO accrual_jobs (_order, username, _status, attempts, next_run_at, last_error, created_at, updated_at)
+		VALUES ($1, $2, 'QUEUED', 0, $3, '', $4, $4) ON CONFLICT (_order) DO NOTHING;`
+	ClaimJobs = `UPDATE accrual_jobs SET _status='RUNNING', attempts=attempts+1, owner=$1, lease_until=$3, updated_at=$2
+		WHERE _order IN (
+			SELECT j._order FROM accrual_jobs j LEFT JOIN orders o ON o._number=j._order
+			WHERE ((j._status='QUEUED' AND j.next_run_at <= $2) OR (j._status='RUNNING' AND j.lease_until < $2))
+			AND CASE WHEN $6 THEN COALESCE(o.provider, '') <> ALL($5) ELSE o.provider = ANY($5) END
+			ORDER BY j.next_run_at LIMIT $4 FOR UPDATE OF j SKIP LOCKED
+		) RETURNING _order, username, _status, attempts, failures, next_run_at, last_error, owner, lease_until,
+		last_status_code, last_checked_at, created_at, updated_at;`
+	RescheduleJob = `UPDATE accrual_jobs SET _status='QUEUED', next_run_at=$1, last_error=$2, updated_at=$3
+		WHERE _order=$4 AND _status='RUNNING' AND owner=$5 AND lease_until > $3;`
+	RetryJob = `UPDATE accrual_jobs SET _status='QUEUED', failures=failures+1, next_run_at=$1, last_error=$2, last_status_code=$3, last_checked_at=$4, updated_at=$4
+		WHERE _order=$5 AND _status='RUNNING' AND owner=$6 AND lease_until > $4;`
+	FailJob = `UPDATE accrual_jobs SET _status='FAILED', failures=failures+1, last_error=$1, last_status_code=$2, last_checked_at=$3, updated_at=$3
+		WHERE _order=$4 AND _status='RUNNING' AND owner=$5 AND lease_until > $3;`
+	RequeueJobs = `INSERT INTO accrual_jobs (_order, username, _status, attempts, next_run_at, last_error, created_at, updated_at)
+		SELECT _number, username, 'QUEUED', 0, $1, '', $1, $1 FROM orders WHERE _status IN ('NEW', 'PROCESSING')
+		ON CONFLICT (_order) DO UPDATE SET _status='QUEUED', next_run_at=$1, updated_at=$1
+		WHERE accrual_jobs._status='QUEUED'
+		OR (accrual_jobs._status='RUNNING' AND (accrual_jobs.owner=$2 OR accrual_jobs.lease_until < $1));`
+	RenewLeases = `UPDATE accrual_jobs SET lease_until=$1 WHERE owner=$2 AND _status='RUNNING';`
+)
+
+const (
+	SaveJobAttempt = `INSERT INTO accrual_job_attempts (_order, attempt, status_code, error, response_body, attempted_at)
+		VALUES ($1, $2, $3, $4, $5, $6);`
+	GetJobAttempts = `SELECT _order, attempt, status_code, error, response_body, attempted_at FROM accrual_job_attempts
+		WHERE _order=$1 ORDER BY attempted_at, id;`
+	SaveDeadLetter = `INSERT INTO dead_letters VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (_order) DO UPDATE SET last_error=$3, status_code=$4, response_body=$5, attempts=$6, failures=$7, failed_at=$8;`
+	GetDeadLetters = `SELECT _order, username, last_error, status_code, response_body, attempts, failures, failed_at
+		FROM dead_letters ORDER BY failed_at DESC;`
+	GetDeadLetter = `SELECT _order, username, last_error, status_code, response_body, attempts, failures, failed_at
+		FROM dead_letters WHERE _order=$1;`
+	DeleteDeadLetter = `DELETE FROM dead_letters WHERE _order=$1;`
+	ResetJob         = `INSERT INTO accrual_jobs (_order, username, _status, attempts, next_run_at, last_error, created_at, updated_at)
+		VALUES ($1, $2, 'QUEUED', 0, $3, '', $3, $3)
+		ON CONFLICT (_order) DO UPDATE SET _status='QUEUED', attempts=0, failures=0, next_run_at=$3, last_error='', created_at=$3, updated_at=$3;`
+	DeleteJob = `WITH attempts AS (DELETE FROM accrual_job_attempts WHERE _order=$1)
+		DELETE FROM accrual_jobs WHERE _order=$1;`
+)
+
+const (
+	GetJob = `SELECT _order, username, _status, attempts, failures, next_run_at, last_error, owner, lease_until,
+		last_status_code, last_checked_at, created_at, updated_at FROM accrual_jobs WHERE _order=$1;`
+	GetJobs = `WITH jobs AS (
+			SELECT *, CASE
+				WHEN _status='QUEUED' AND failures > 0 THEN 'retrying'
+				WHEN _status='QUEUED' THEN 'queued'
+				WHEN _status='RUNNING' THEN 'running'
+				ELSE 'dead'
+			END AS state FROM accrual_jobs WHERE _status <> 'DONE'
+		)
+		SELECT _order, username, _status, attempts, failures, next_run_at, last_error, owner, lease_until,
+		last_status_code, last_checked_at, created_at, updated_at, state
+		FROM jobs WHERE $1='' OR state=$1 ORDER BY next_run_at LIMIT $2;`
+)
+
+const (
+	UpdateChecks = `WITH checks (_number, username, _status, accrual, status_code, checked_at, next_run_at) AS (VALUES %s),
+		owned AS (
+			SELECT accrual_jobs._order FROM accrual_jobs JOIN checks ON accrual_jobs._order=checks._number
+			WHERE accrual_jobs._status='QUEUED'
+			OR (accrual_jobs._status='RUNNING' AND accrual_jobs.owner=$2 AND accrual_jobs.lease_until > $1)
+			FOR UPDATE OF accrual_jobs
+		),
+		updated_orders AS (
+			UPDATE orders SET _status=checks._status, accrual=checks.accrual FROM checks JOIN owned ON owned._order=checks._number
+			WHERE orders._number=checks._number AND orders.username=checks.username
+			AND (orders._status, checks._status) IN (%s)
+		)
+		UPDATE accrual_jobs SET _status='QUEUED', next_run_at=checks.next_run_at, last_error='',
+		last_status_code=checks.status_code, last_checked_at=checks.checked_at, updated_at=$1
+		FROM checks JOIN owned ON owned._order=checks._number WHERE accrual_jobs._order=checks._number;`
+	UpdateChecksRow = `($%d, $%d, $%d::STATUS, $%d::FLOAT, $%d::INTEGER, $%d::TIMESTAMP, $%d::TIMESTAMP)`
+)
+
+const (
+	CreditOrder   = `UPDATE orders SET _status=$1, accrual=$2 WHERE username=$3 AND _number=$4 AND _status IN (%s);`
+	CreditBalance = `INSERT INTO balances VALUES ($1, $2, 0) ON CONFLICT (username) DO UPDATE SET _current=balances._current+$2;`
+	CreditJob     = `UPDATE accrual_jobs SET _status='DONE', last_status_code=$1, last_checked_at=$2, updated_at=$2
+		WHERE _order=$3 AND _status IN ('QUEUED', 'RUNNING');`
+)
+
+const (
+	SetOrderProvider = `UPDATE orders SET provider=$1 WHERE _number=$2;`
+)
+
+const (
+	WithdrawBalance = `UPDATE balances SET _current=_current-$1, withdrawn=withdrawn+$1 WHERE username=$2 AND _current >= $1;`
+)
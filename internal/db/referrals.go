@@ -0,0 +1,140 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/nmramorov/gophemart/internal/logger"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+func (r *repos) SaveReferralCode(ctx context.Context, username string, code string) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	_, err := r.db.Exec(ctx, SaveReferralCode, username, code)
+	if err != nil {
+		logger.ErrorLog.Printf("error during saving referral code for %s: %e", username, err)
+		return err
+	}
+	return nil
+}
+
+func (r *repos) GetReferralCode(ctx context.Context, username string) (string, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	var code string
+	err := r.db.QueryRow(ctx, GetReferralCode, username).Scan(&code)
+	if err == pgx.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		logger.ErrorLog.Printf("error during getting referral code for %s: %e", username, err)
+		return "", err
+	}
+	return code, nil
+}
+
+func (r *repos) GetReferrerByCode(ctx context.Context, code string) (string, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	var username string
+	err := r.db.QueryRow(ctx, GetReferrerByCode, code).Scan(&username)
+	if err == pgx.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		logger.ErrorLog.Printf("error during getting referrer by code: %e", err)
+		return "", err
+	}
+	return username, nil
+}
+
+// LockReferrer locks the referral code of referrer until the end of the
+// unit of work, so the referrals of referrer are counted and saved by one
+// registration at a time.
+func (r *repos) LockReferrer(ctx context.Context, referrer string) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	var username string
+	err := r.db.QueryRow(ctx, LockReferrer, referrer).Scan(&username)
+	if err != nil && err != pgx.ErrNoRows {
+		logger.ErrorLog.Printf("error during locking referrer %s: %e", referrer, err)
+		return err
+	}
+	return nil
+}
+
+func (r *repos) SaveReferral(ctx context.Context, referral *models.Referral) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	_, err := r.db.Exec(ctx, SaveReferral, referral.Referrer, referral.Referee, referral.Status, referral.CreatedAt, referral.RewardedAt)
+	if err != nil {
+		logger.ErrorLog.Printf("error during saving referral of %s: %e", referral.Referee, err)
+		return err
+	}
+	return nil
+}
+
+func (r *repos) CountReferrals(ctx context.Context, referrer string) (int, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	var count int
+	err := r.db.QueryRow(ctx, CountReferrals, referrer).Scan(&count)
+	if err != nil {
+		logger.ErrorLog.Printf("error during counting referrals of %s: %e", referrer, err)
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *repos) GetReferrals(ctx context.Context, referrer string) ([]*models.Referral, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	rows, err := r.db.Query(ctx, GetReferrals, referrer)
+	if err != nil {
+		logger.ErrorLog.Printf("error during getting referrals of %s: %e", referrer, err)
+		return nil, err
+	}
+	defer rows.Close()
+	foundReferrals := []*models.Referral{}
+	for rows.Next() {
+		var referral models.Referral
+		if err := rows.Scan(&referral.Referrer, &referral.Referee, &referral.Status, &referral.CreatedAt, &referral.RewardedAt); err != nil {
+			logger.ErrorLog.Printf("error scanning referral from db: %e", err)
+			return foundReferrals, err
+		}
+		foundReferrals = append(foundReferrals, &referral)
+	}
+	if err := rows.Err(); err != nil {
+		return foundReferrals, err
+	}
+	return foundReferrals, nil
+}
+
+func (r *repos) GetReferral(ctx context.Context, referee string) (*models.Referral, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	referral := &models.Referral{}
+	err := r.db.QueryRow(ctx, GetReferral, referee).Scan(&referral.Referrer, &referral.Referee, &referral.Status, &referral.CreatedAt, &referral.RewardedAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		logger.ErrorLog.Printf("error during getting referral of %s: %e", referee, err)
+		return nil, err
+	}
+	return referral, nil
+}
+
+func (r *repos) CompleteReferral(ctx context.Context, referee string, rewardedAt time.Time) (bool, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	result, err := r.db.Exec(ctx, CompleteReferral, rewardedAt, referee)
+	if err != nil {
+		logger.ErrorLog.Printf("error during completing referral of %s: %e", referee, err)
+		return false, err
+	}
+	return result.RowsAffected() == 1, nil
+}
@@ -0,0 +1,187 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/nmramorov/gophemart/internal/logger"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+type UserRepository interface {
+	SaveUserInfo(context.Context, *models.UserInfo) error
+	GetUserInfo(context.Context, *models.UserInfo) (*models.UserInfo, error)
+}
+
+type SessionRepository interface {
+	SaveSession(context.Context, string, *models.Session) error
+	GetSession(context.Context, string) (*models.Session, error)
+	GetUsernameByToken(context.Context, string) (string, error)
+}
+
+type NotificationRepository interface {
+	GetNotificationPreferences(context.Context, string) (*models.NotificationPreferences, error)
+	SaveNotificationPreferences(context.Context, *models.NotificationPreferences) error
+	HasDevice(context.Context, string, string) (bool, error)
+	SaveDevice(context.Context, string, string, time.Time) error
+}
+
+type OrderRepository interface {
+	GetOrder(context.Context, string, string) (*models.Order, error)
+	SaveOrder(context.Context, *models.Order) error
+	GetOrders(context.Context, string) ([]*models.Order, error)
+	GetAllOrders(context.Context) ([]*models.Order, error)
+	UpdateOrder(context.Context, string, string, models.OrderStatus, float64) error
+	SetOrderProvider(context.Context, string, string) error
+	CreditOrder(context.Context, *models.Credit) (bool, error)
+	UpdateChecks(context.Context, string, []*models.OrderCheck, time.Time) (int64, error)
+}
+
+type BalanceRepository interface {
+	GetUserBalance(context.Context, string) (*models.Balance, error)
+	SaveUserBalance(context.Context, string, *models.Balance) (*models.Balance, error)
+	UpdateUserBalance(context.Context, string, *models.Balance) (*models.Balance, error)
+	CreditBalance(context.Context, string, float64) error
+	DebitBalance(context.Context, string, float64) error
+}
+
+type WithdrawalRepository interface {
+	GetWithdrawals(context.Context, string) ([]*models.Withdrawal, error)
+	SaveWithdrawal(context.Context, *models.Withdrawal) error
+	Withdraw(context.Context, *models.Withdrawal, []*models.AccrualLot) error
+}
+
+type LotRepository interface {
+	SaveAccrualLot(context.Context, *models.AccrualLot) error
+	GetActiveLots(context.Context, string, time.Time) ([]*models.AccrualLot, error)
+	LockLots(context.Context, string) ([]*models.AccrualLot, error)
+	GetExpiredLots(context.Context, time.Time) ([]*models.AccrualLot, error)
+	ExpireLot(context.Context, int64) (float64, error)
+	GetAccruedSince(context.Context, string, time.Time) (float64, error)
+}
+
+type ExpirationRepository interface {
+	SaveExpiration(context.Context, *models.Expiration) error
+}
+
+type TierRepository interface {
+	GetTiers(context.Context) ([]*models.Tier, error)
+}
+
+type CampaignRepository interface {
+	GetCampaigns(context.Context) ([]*models.Campaign, error)
+	GetActiveCampaigns(context.Context, time.Time) ([]*models.Campaign, error)
+	GetCampaign(context.Context, int64) (*models.Campaign, error)
+	SaveCampaign(context.Context, *models.Campaign) error
+	UpdateCampaign(context.Context, *models.Campaign) error
+	DeleteCampaign(context.Context, int64, time.Time) error
+	SaveCampaignBonus(context.Context, *models.CampaignBonus, int) (bool, error)
+	GetOrderBonuses(context.Context, string) ([]*models.CampaignBonus, error)
+}
+
+type ReferralRepository interface {
+	SaveReferralCode(context.Context, string, string) error
+	GetReferralCode(context.Context, string) (string, error)
+	GetReferrerByCode(context.Context, string) (string, error)
+	LockReferrer(context.Context, string) error
+	SaveReferral(context.Context, *models.Referral) error
+	CountReferrals(context.Context, string) (int, error)
+	GetReferrals(context.Context, string) ([]*models.Referral, error)
+	GetReferral(context.Context, string) (*models.Referral, error)
+	CompleteReferral(context.Context, string, time.Time) (bool, error)
+}
+
+type JobRepository interface {
+	EnqueueJob(context.Context, string, string, time.Time, time.Time) error
+	ClaimJobs(context.Context, string, time.Time, time.Time, int, []string, bool) ([]*models.AccrualJob, error)
+	RescheduleJob(context.Context, string, string, time.Time, string, time.Time) error
+	RetryJob(context.Context, string, string, time.Time, string, int, time.Time) error
+	FailJob(context.Context, string, string, string, int, time.Time) error
+	RequeueJobs(context.Context, string, time.Time) (int64, error)
+	RenewLeases(context.Context, string, time.Time) (int64, error)
+	ResetJob(context.Context, string, string, time.Time) error
+	DeleteJob(context.Context, string) error
+	GetJob(context.Context, string) (*models.AccrualJob, error)
+	GetJobs(context.Context, string, int) ([]*models.AccrualJob, error)
+}
+
+type DeadLetterRepository interface {
+	SaveJobAttempt(context.Context, *models.JobAttempt) error
+	GetJobAttempts(context.Context, string) ([]*models.JobAttempt, error)
+	SaveDeadLetter(context.Context, *models.DeadLetter) error
+	GetDeadLetters(context.Context) ([]*models.DeadLetter, error)
+	GetDeadLetter(context.Context, string) (*models.DeadLetter, error)
+	DeleteDeadLetter(context.Context, string) (bool, error)
+}
+
+// Repos is the set of repositories handed to a unit of work.
+type Repos interface {
+	UserRepository
+	SessionRepository
+	NotificationRepository
+	OrderRepository
+	BalanceRepository
+	WithdrawalRepository
+	LotRepository
+	ExpirationRepository
+	TierRepository
+	CampaignRepository
+	ReferralRepository
+	JobRepository
+	DeadLetterRepository
+}
+
+// UnitOfWork runs fn with repositories bound to a single transaction. The
+// transaction is committed when fn returns nil and rolled back otherwise.
+type UnitOfWork interface {
+	WithTx(context.Context, func(tx Repos) error) error
+}
+
+// querier is what the repositories run their queries on, the pool or a
+// transaction. Begin on a transaction starts a savepoint, so methods with
+// several statements stay atomic on both.
+type querier interface {
+	Exec(context.Context, string, ...interface{}) (pgconn.CommandTag, error)
+	Query(context.Context, string, ...interface{}) (pgx.Rows, error)
+	QueryRow(context.Context, string, ...interface{}) pgx.Row
+	SendBatch(context.Context, *pgx.Batch) pgx.BatchResults
+	Begin(context.Context) (pgx.Tx, error)
+}
+
+// repos implements Repos on top of a querier, every query is bounded by
+// Timeout.
+type repos struct {
+	db      querier
+	Timeout time.Duration
+}
+
+// withTimeout bounds a single query by Timeout. The deadline of the caller
+// still applies, so a gone client cancels its queries.
+func (r *repos) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, r.Timeout)
+}
+
+// WithTx runs fn with the repositories bound to a new transaction. The
+// error of fn is returned as is after the rollback.
+func (c *DBCursor) WithTx(ctx context.Context, fn func(tx Repos) error) error {
+	tx, err := c.Pool.Begin(ctx)
+	if err != nil {
+		logger.ErrorLog.Printf("error starting transaction: %e", err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if err := fn(&repos{db: tx, Timeout: c.Timeout}); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		logger.ErrorLog.Printf("error committing transaction: %e", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/nmramorov/gophemart/internal/logger"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+func (r *repos) SaveSession(ctx context.Context, id string, session *models.Session) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	_, err := r.db.Exec(ctx, SaveSession, session.Username, session.Token, session.ExpiresAt)
+	if err != nil {
+		logger.ErrorLog.Printf("error inserting row %s to db: %e", id, err)
+		return err
+	}
+	return nil
+}
+
+func (r *repos) SaveUserInfo(ctx context.Context, info *models.UserInfo) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	_, err := r.db.Exec(ctx, SaveUserInfo, info.Username, info.Password)
+	if err != nil {
+		logger.ErrorLog.Printf("error inserting row into Userinfo: %e", err)
+		return err
+	}
+	return nil
+}
+
+func (r *repos) GetUserInfo(ctx context.Context, info *models.UserInfo) (*models.UserInfo, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	row := r.db.QueryRow(ctx, GetUserInfo, info.Username)
+	foundInfo := &models.UserInfo{}
+	err := row.Scan(&foundInfo.Username, &foundInfo.Password)
+	if err != nil {
+		logger.ErrorLog.Printf("error scanning userinfo from db: %e", err)
+		return nil, err
+	}
+	return foundInfo, nil
+}
+
+func (r *repos) GetUsernameByToken(ctx context.Context, token string) (string, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	row := r.db.QueryRow(ctx, GetSessionUser, token)
+	foundSession := &models.Session{}
+	err := row.Scan(&foundSession.Username)
+	if err != nil {
+		logger.ErrorLog.Printf("error scanning session username from db: %e", err)
+		return "", err
+	}
+	return foundSession.Username, nil
+}
+
+func (r *repos) GetSession(ctx context.Context, token string) (*models.Session, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	row := r.db.QueryRow(ctx, GetSession, token)
+	foundSession := &models.Session{}
+
+	err := row.Scan(&foundSession.Username, &foundSession.Token, &foundSession.ExpiresAt)
+	if err != nil {
+		logger.ErrorLog.Printf("error scanning session from db: %e", err)
+		return nil, err
+	}
+	return foundSession, nil
+}
+
+func (r *repos) GetNotificationPreferences(ctx context.Context, username string) (*models.NotificationPreferences, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	p := &models.NotificationPreferences{}
+	err := r.db.QueryRow(ctx, GetNotificationPreferences, username).Scan(&p.User, &p.Email, &p.Orders, &p.Withdrawals, &p.Logins)
+	if err == pgx.ErrNoRows {
+		return &models.NotificationPreferences{User: username, Orders: true, Withdrawals: true, Logins: true}, nil
+	}
+	if err != nil {
+		logger.ErrorLog.Printf("error during getting notification preferences of %s: %e", username, err)
+		return nil, err
+	}
+	return p, nil
+}
+
+func (r *repos) SaveNotificationPreferences(ctx context.Context, p *models.NotificationPreferences) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	_, err := r.db.Exec(ctx, SaveNotificationPreferences, p.User, p.Email, p.Orders, p.Withdrawals, p.Logins)
+	if err != nil {
+		logger.ErrorLog.Printf("error during saving notification preferences of %s: %e", p.User, err)
+		return err
+	}
+	return nil
+}
+
+func (r *repos) HasDevice(ctx context.Context, username string, fingerprint string) (bool, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	var exists bool
+	err := r.db.QueryRow(ctx, HasDevice, username, fingerprint).Scan(&exists)
+	if err != nil {
+		logger.ErrorLog.Printf("error during checking device of %s: %e", username, err)
+		return false, err
+	}
+	return exists, nil
+}
+
+func (r *repos) SaveDevice(ctx context.Context, username string, fingerprint string, seenAt time.Time) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	_, err := r.db.Exec(ctx, SaveDevice, username, fingerprint, seenAt)
+	if err != nil {
+		logger.ErrorLog.Printf("error during saving device of %s: %e", username, err)
+		return err
+	}
+	return nil
+}
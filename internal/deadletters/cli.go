@@ -0,0 +1,74 @@
+package deadletters
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nmramorov/gophemart/internal/db"
+)
+
+const USAGE = `usage: gophermart [flags] deadletters <command>
+
+commands:
+  list              list dead letters, newest first
+  show <order>      print a dead letter with its attempt history
+  requeue <order>   put the order back into the accrual queue
+  discard <order>   drop the dead letter and its job`
+
+var errUsage = errors.New(USAGE)
+
+// RunCLI executes a deadletters subcommand against the database.
+func RunCLI(ctx context.Context, cursor Store, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	if args[0] == "list" {
+		return list(ctx, cursor, out)
+	}
+	if len(args) != 2 {
+		return errUsage
+	}
+	number := args[1]
+	switch args[0] {
+	case "show":
+		letter, err := Inspect(ctx, cursor, number)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(letter)
+	case "requeue":
+		if err := Requeue(ctx, cursor, number, time.Now()); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "order %s requeued\n", number)
+		return nil
+	case "discard":
+		if err := Discard(ctx, cursor, number); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "order %s discarded\n", number)
+		return nil
+	}
+	return errUsage
+}
+
+func list(ctx context.Context, cursor db.DeadLetterRepository, out io.Writer) error {
+	letters, err := cursor.GetDeadLetters(ctx)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ORDER\tLOGIN\tFAILURES\tFAILED AT\tLAST ERROR")
+	for _, letter := range letters {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", letter.Order, letter.User, letter.Failures,
+			letter.FailedAt.Format(time.RFC3339), letter.LastError)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,82 @@
+package deadletters
+
+import (
+	"context"
+	"time"
+
+	"github.com/nmramorov/gophemart/internal/db"
+	"github.com/nmramorov/gophemart/internal/errors"
+	"github.com/nmramorov/gophemart/internal/logger"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+// Store is what the dead letter commands need, the dead letters and the
+// unit of work putting their jobs back into the queue.
+type Store interface {
+	db.DeadLetterRepository
+	db.UnitOfWork
+}
+
+// Inspect returns the dead letter together with the history of failed
+// attempts of its job.
+func Inspect(ctx context.Context, repos db.DeadLetterRepository, number string) (*models.DeadLetter, error) {
+	letter, err := repos.GetDeadLetter(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	if letter == nil {
+		return nil, errors.ErrDeadLetterNotFound
+	}
+	history, err := repos.GetJobAttempts(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	letter.History = history
+	return letter, nil
+}
+
+// Requeue puts the order back into the accrual queue with a fresh retry
+// budget. The attempt history is kept.
+func Requeue(ctx context.Context, cursor Store, number string, now time.Time) error {
+	letter, err := cursor.GetDeadLetter(ctx, number)
+	if err != nil {
+		return err
+	}
+	if letter == nil {
+		return errors.ErrDeadLetterNotFound
+	}
+	err = cursor.WithTx(ctx, func(tx db.Repos) error {
+		if err := tx.UpdateOrder(ctx, letter.User, number, models.OrderNew, 0); err != nil {
+			return err
+		}
+		if err := tx.ResetJob(ctx, number, letter.User, now); err != nil {
+			return err
+		}
+		_, err := tx.DeleteDeadLetter(ctx, number)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	logger.InfoLog.Printf("Requeued dead letter for order %s", number)
+	return nil
+}
+
+// Discard forgets the dead letter and its job. The order stays FAILED.
+func Discard(ctx context.Context, cursor Store, number string) error {
+	err := cursor.WithTx(ctx, func(tx db.Repos) error {
+		deleted, err := tx.DeleteDeadLetter(ctx, number)
+		if err != nil {
+			return err
+		}
+		if !deleted {
+			return errors.ErrDeadLetterNotFound
+		}
+		return tx.DeleteJob(ctx, number)
+	})
+	if err != nil {
+		return err
+	}
+	logger.InfoLog.Printf("Discarded dead letter for order %s", number)
+	return nil
+}
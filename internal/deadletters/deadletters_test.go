@@ -0,0 +1,116 @@
+package deadletters
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nmramorov/gophemart/internal/db"
+	"github.com/nmramorov/gophemart/internal/errors"
+	"github.com/nmramorov/gophemart/internal/mocks"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+func newCursor(now time.Time) *db.Cursor {
+	ctx := context.Background()
+	cursor := &db.Cursor{DBInterface: mocks.NewMock()}
+	cursor.SaveOrder(ctx, &models.Order{Number: "12345678903", Username: "test", Status: models.OrderFailed})
+	cursor.EnqueueJob(ctx, "12345678903", "test", now.Add(-time.Hour), now.Add(-time.Hour))
+	cursor.ClaimJobs(ctx, "a", now, now.Add(time.Minute), 1, nil, true)
+	cursor.FailJob(ctx, "12345678903", "a", "unexpected accrual response", 200, now)
+	cursor.SaveJobAttempt(ctx, &models.JobAttempt{
+		Order:        "12345678903",
+		Attempt:      1,
+		StatusCode:   500,
+		Error:        "unexpected accrual response",
+		ResponseBody: "oops",
+		AttemptedAt:  now,
+	})
+	cursor.SaveDeadLetter(ctx, &models.DeadLetter{
+		Order:        "12345678903",
+		User:         "test",
+		LastError:    "unexpected accrual response",
+		StatusCode:   500,
+		ResponseBody: "oops",
+		Attempts:     1,
+		Failures:     1,
+		FailedAt:     now,
+	})
+	return cursor
+}
+
+func TestInspect(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	cursor := newCursor(now)
+
+	letter, err := Inspect(ctx, cursor, "12345678903")
+	assert.NoError(t, err)
+	assert.Equal(t, "oops", letter.ResponseBody)
+	assert.Len(t, letter.History, 1)
+
+	_, err = Inspect(ctx, cursor, "1")
+	assert.Equal(t, errors.ErrDeadLetterNotFound, err)
+}
+
+func TestRequeue(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	cursor := newCursor(now)
+
+	assert.NoError(t, Requeue(ctx, cursor, "12345678903", now))
+	order, _ := cursor.GetOrder(ctx, "test", "12345678903")
+	assert.Equal(t, models.OrderNew, order.Status)
+	letter, _ := cursor.GetDeadLetter(ctx, "12345678903")
+	assert.Nil(t, letter)
+
+	jobs, _ := cursor.ClaimJobs(ctx, "test", now, time.Now(), 10, nil, true)
+	assert.Len(t, jobs, 1)
+	assert.Equal(t, 0, jobs[0].Failures)
+	assert.Equal(t, now, jobs[0].CreatedAt)
+
+	assert.Equal(t, errors.ErrDeadLetterNotFound, Requeue(ctx, cursor, "12345678903", now))
+}
+
+func TestDiscard(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	cursor := newCursor(now)
+
+	assert.NoError(t, Discard(ctx, cursor, "12345678903"))
+	letters, _ := cursor.GetDeadLetters(ctx)
+	assert.Empty(t, letters)
+	history, _ := cursor.GetJobAttempts(ctx, "12345678903")
+	assert.Empty(t, history)
+	order, _ := cursor.GetOrder(ctx, "test", "12345678903")
+	assert.Equal(t, models.OrderFailed, order.Status)
+
+	assert.Equal(t, errors.ErrDeadLetterNotFound, Discard(ctx, cursor, "12345678903"))
+}
+
+func TestRunCLI(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	cursor := newCursor(now)
+	out := &bytes.Buffer{}
+
+	assert.NoError(t, RunCLI(ctx, cursor, []string{"list"}, out))
+	assert.True(t, strings.HasPrefix(out.String(), "ORDER"))
+	assert.Contains(t, out.String(), "12345678903")
+
+	out.Reset()
+	assert.NoError(t, RunCLI(ctx, cursor, []string{"show", "12345678903"}, out))
+	assert.Contains(t, out.String(), `"response_body": "oops"`)
+
+	out.Reset()
+	assert.NoError(t, RunCLI(ctx, cursor, []string{"requeue", "12345678903"}, out))
+	assert.Equal(t, "order 12345678903 requeued\n", out.String())
+
+	assert.Equal(t, errors.ErrDeadLetterNotFound, RunCLI(ctx, cursor, []string{"discard", "12345678903"}, out))
+	assert.Equal(t, errUsage, RunCLI(ctx, cursor, []string{"show"}, out))
+	assert.Equal(t, errUsage, RunCLI(ctx, cursor, nil, out))
+}
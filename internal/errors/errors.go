@@ -7,3 +7,10 @@ var ErrDatabaseUnreachable error = errors.New("database unreachable")
 var ErrDatabaseSQLQuery error = errors.New("error with SQL query")
 var ErrDatabaseMigration error = errors.New("error with migrations")
 var ErrJobChannelClosed error = errors.New("jobs channel closed")
+var ErrUnknownEvent error = errors.New("unknown notification event")
+var ErrAccrualResponse error = errors.New("unexpected accrual response")
+var ErrDeadLetterNotFound error = errors.New("dead letter not found")
+var ErrUnknownStatus error = errors.New("unknown order status")
+var ErrOrderTransition error = errors.New("forbidden order status transition")
+var ErrInsufficientBalance error = errors.New("not enough points on balance")
+var ErrLeaseLost error = errors.New("job lease lost")
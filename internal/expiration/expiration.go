@@ -0,0 +1,194 @@
+package expiration
+
+import (
+	"context"
+	"time"
+
+	"github.com/nmramorov/gophemart/internal/db"
+	"github.com/nmramorov/gophemart/internal/logger"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+// EXPIRINGWINDOW is how far ahead the balance looks for points about to expire.
+const EXPIRINGWINDOW = 30 * 24 * time.Hour
+
+// Store is what the sweeper needs, the expired lots and the unit of work
+// expiring each of them.
+type Store interface {
+	db.LotRepository
+	db.UnitOfWork
+}
+
+// Ledger is what a withdrawal needs within its unit of work, the lots and
+// the balance it is taken off and the expirations of overdue lots.
+type Ledger interface {
+	db.LotRepository
+	db.ExpirationRepository
+	db.BalanceRepository
+	db.WithdrawalRepository
+}
+
+// expirer zeroes lots and records their expiration.
+type expirer interface {
+	db.LotRepository
+	db.ExpirationRepository
+}
+
+type Sweeper struct {
+	Cursor   Store
+	Interval time.Duration
+	context  context.Context
+	Shutdown context.CancelFunc
+}
+
+func NewSweeper(cursor Store, interval time.Duration, parent *context.Context) *Sweeper {
+	ctx, cancel := context.WithCancel(*parent)
+	return &Sweeper{
+		Cursor:   cursor,
+		Interval: interval,
+		context:  ctx,
+		Shutdown: cancel,
+	}
+}
+
+// NewLot builds a lot for the accrual of a PROCESSED order which expires
+// ttlMonths after accrual. base is the accrual before the tier multiplier.
+func NewLot(username string, order string, amount float64, base float64, accruedAt time.Time, ttlMonths int) *models.AccrualLot {
+	return &models.AccrualLot{
+		User:      username,
+		Order:     order,
+		Amount:    amount,
+		Base:      base,
+		Remaining: amount,
+		AccruedAt: accruedAt,
+		ExpiresAt: accruedAt.AddDate(0, ttlMonths, 0),
+	}
+}
+
+// TakeLots takes sum out of lots oldest-first and returns the lots it
+// changed with their new remaining points.
+func TakeLots(lots []*models.AccrualLot, username string, sum float64) []*models.AccrualLot {
+	taken := []*models.AccrualLot{}
+	for _, lot := range lots {
+		if sum <= 0 {
+			break
+		}
+		amount := lot.Remaining
+		if sum < amount {
+			amount = sum
+		}
+		consumed := *lot
+		consumed.Remaining = lot.Remaining - amount
+		taken = append(taken, &consumed)
+		sum -= amount
+	}
+	if sum > 0 {
+		logger.InfoLog.Printf("Withdrawal for %s exceeds tracked lots by %f", username, sum)
+	}
+	return taken
+}
+
+// ExpiringSum returns the points of the user which expire within window.
+func ExpiringSum(ctx context.Context, repos db.LotRepository, username string, now time.Time, window time.Duration) (float64, error) {
+	lots, err := repos.GetActiveLots(ctx, username, now)
+	if err != nil {
+		return 0, err
+	}
+	var sum float64
+	deadline := now.Add(window)
+	for _, lot := range lots {
+		if !lot.ExpiresAt.After(deadline) {
+			sum += lot.Remaining
+		}
+	}
+	return sum, nil
+}
+
+// Withdraw takes the withdrawal off the balance and the lots of the user
+// within tx, oldest lot first. Lots which expired but were not swept yet are
+// expired first, so their points can not be withdrawn and later taken off
+// the balance by the sweep a second time. Every lot of the user is locked
+// before the balance, in the same order as the sweep.
+func Withdraw(ctx context.Context, tx Ledger, withdrawal *models.Withdrawal) error {
+	lots, err := tx.LockLots(ctx, withdrawal.User)
+	if err != nil {
+		return err
+	}
+	active := []*models.AccrualLot{}
+	var overdue float64
+	for _, lot := range lots {
+		if lot.ExpiresAt.After(withdrawal.ProcessedAt) {
+			active = append(active, lot)
+			continue
+		}
+		expired, err := expire(ctx, tx, lot, withdrawal.ProcessedAt)
+		if err != nil {
+			return err
+		}
+		overdue += expired
+	}
+	if overdue > 0 {
+		if err := tx.DebitBalance(ctx, withdrawal.User, overdue); err != nil {
+			return err
+		}
+	}
+	return tx.Withdraw(ctx, withdrawal, TakeLots(active, withdrawal.User, withdrawal.Sum))
+}
+
+// expire zeroes the lot and writes an expiration entry for the points it
+// had left, which are returned. The points expired are the ones left when
+// the lot is zeroed, so a lot expired by another replica or used by a
+// withdrawal since it was listed is never taken off twice.
+func expire(ctx context.Context, tx expirer, lot *models.AccrualLot, now time.Time) (float64, error) {
+	expired, err := tx.ExpireLot(ctx, lot.ID)
+	if err != nil || expired == 0 {
+		return 0, err
+	}
+	if err := tx.SaveExpiration(ctx, &models.Expiration{
+		User:      lot.User,
+		Order:     lot.Order,
+		Sum:       expired,
+		ExpiredAt: now,
+	}); err != nil {
+		return 0, err
+	}
+	logger.InfoLog.Printf("Expired %f points of order %s for user %s", expired, lot.Order, lot.User)
+	return expired, nil
+}
+
+// Sweep expires every expired lot and takes its remaining points off the
+// user's current balance, one unit of work per lot. A lot which fails to
+// expire is logged and left for the next sweep.
+func (s *Sweeper) Sweep(now time.Time) error {
+	lots, err := s.Cursor.GetExpiredLots(s.context, now)
+	if err != nil {
+		return err
+	}
+	for _, lot := range lots {
+		if err := s.Cursor.WithTx(s.context, func(tx db.Repos) error {
+			expired, err := expire(s.context, tx, lot, now)
+			if err != nil || expired == 0 {
+				return err
+			}
+			return tx.DebitBalance(s.context, lot.User, expired)
+		}); err != nil {
+			logger.ErrorLog.Printf("Error expiring lot of order %s for user %s: %e", lot.Order, lot.User, err)
+		}
+	}
+	return nil
+}
+
+func (s *Sweeper) Run() {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.context.Done():
+			return
+		case now := <-ticker.C:
+			if err := s.Sweep(now); err != nil {
+				logger.ErrorLog.Printf("Expiry sweep failed: %e", err)
+			}
+		}
+	}
+}
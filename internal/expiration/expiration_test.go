@@ -0,0 +1,169 @@
+package expiration
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nmramorov/gophemart/internal/db"
+	"github.com/nmramorov/gophemart/internal/errors"
+	"github.com/nmramorov/gophemart/internal/mocks"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+func newTestCursor(now time.Time) *db.Cursor {
+	ctx := context.Background()
+	cursor := &db.Cursor{DBInterface: mocks.NewMock()}
+	cursor.SaveUserBalance(ctx, "test", &models.Balance{User: "test", Current: 300})
+	cursor.SaveAccrualLot(ctx, NewLot("test", "1", 100, 100, now.AddDate(0, -13, 0), 12))
+	cursor.SaveAccrualLot(ctx, NewLot("test", "2", 100, 100, now.AddDate(0, -12, 10), 12))
+	cursor.SaveAccrualLot(ctx, NewLot("test", "3", 100, 100, now.AddDate(0, -1, 0), 12))
+	return cursor
+}
+
+func TestTakeLotsWithdraw(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	cursor := newTestCursor(now)
+
+	lots, _ := cursor.GetActiveLots(ctx, "test", now)
+	taken := TakeLots(lots, "test", 150)
+	assert.Len(t, taken, 2)
+	assert.Equal(t, float64(0), taken[0].Remaining)
+	assert.Equal(t, float64(50), taken[1].Remaining)
+	assert.Equal(t, float64(100), lots[1].Remaining)
+
+	withdrawal := &models.Withdrawal{User: "test", Order: "2377225624", Sum: 150, ProcessedAt: now}
+	assert.NoError(t, cursor.Withdraw(ctx, withdrawal, taken))
+	tooMuch := &models.Withdrawal{User: "test", Order: "12345678903", Sum: 200, ProcessedAt: now}
+	assert.ErrorIs(t, cursor.Withdraw(ctx, tooMuch, nil), errors.ErrInsufficientBalance)
+
+	balance, _ := cursor.GetUserBalance(ctx, "test")
+	assert.Equal(t, float64(150), balance.Current)
+	assert.Equal(t, float64(150), balance.Withdrawn)
+	lots, _ = cursor.GetActiveLots(ctx, "test", now)
+	assert.Len(t, lots, 1)
+	assert.Equal(t, float64(50), lots[0].Remaining)
+	withdrawals, _ := cursor.GetWithdrawals(ctx, "test")
+	assert.Len(t, withdrawals, 1)
+}
+
+func TestExpiringSum(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	cursor := newTestCursor(now)
+
+	sum, err := ExpiringSum(ctx, cursor, "test", now, EXPIRINGWINDOW)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(100), sum)
+}
+
+func TestSweep(t *testing.T) {
+	now := time.Now()
+	cursor := newTestCursor(now)
+	ctx := context.Background()
+	sweeper := NewSweeper(cursor, time.Hour, &ctx)
+
+	err := sweeper.Sweep(now)
+	assert.NoError(t, err)
+
+	balance, _ := cursor.GetUserBalance(ctx, "test")
+	assert.Equal(t, float64(200), balance.Current)
+
+	expired, _ := cursor.GetExpiredLots(ctx, now)
+	assert.Equal(t, 0, len(expired))
+
+	err = sweeper.Sweep(now)
+	assert.NoError(t, err)
+	balance, _ = cursor.GetUserBalance(ctx, "test")
+	assert.Equal(t, float64(200), balance.Current)
+}
+
+func TestConcurrentSweeps(t *testing.T) {
+	now := time.Now()
+	cursor := newTestCursor(now)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, NewSweeper(cursor, time.Hour, &ctx).Sweep(now))
+		}()
+	}
+	wg.Wait()
+
+	balance, _ := cursor.GetUserBalance(ctx, "test")
+	assert.Equal(t, float64(200), balance.Current)
+}
+
+func TestWithdrawExpiresOverdueLots(t *testing.T) {
+	now := time.Now()
+	cursor := newTestCursor(now)
+	ctx := context.Background()
+	withdraw := func(sum float64) error {
+		return cursor.WithTx(ctx, func(tx db.Repos) error {
+			return Withdraw(ctx, tx, &models.Withdrawal{User: "test", Order: "2377225624", Sum: sum, ProcessedAt: now})
+		})
+	}
+
+	assert.ErrorIs(t, withdraw(250), errors.ErrInsufficientBalance)
+	balance, _ := cursor.GetUserBalance(ctx, "test")
+	assert.Equal(t, float64(300), balance.Current)
+
+	assert.NoError(t, withdraw(200))
+	balance, _ = cursor.GetUserBalance(ctx, "test")
+	assert.Equal(t, float64(0), balance.Current)
+	assert.Equal(t, float64(200), balance.Withdrawn)
+
+	assert.NoError(t, NewSweeper(cursor, time.Hour, &ctx).Sweep(now))
+	balance, _ = cursor.GetUserBalance(ctx, "test")
+	assert.Equal(t, float64(0), balance.Current)
+	lots, _ := cursor.GetActiveLots(ctx, "test", now)
+	assert.Empty(t, lots)
+}
+
+// failingExpiry fails to expire the lot with the given id.
+type failingExpiry struct {
+	db.Repos
+	id int64
+}
+
+func (f *failingExpiry) ExpireLot(ctx context.Context, id int64) (float64, error) {
+	if id == f.id {
+		return 0, errors.ErrDatabaseSQLQuery
+	}
+	return f.Repos.ExpireLot(ctx, id)
+}
+
+type failingExpiryDB struct {
+	db.DBInterface
+	id int64
+}
+
+func (f *failingExpiryDB) WithTx(ctx context.Context, fn func(tx db.Repos) error) error {
+	return f.DBInterface.WithTx(ctx, func(tx db.Repos) error {
+		return fn(&failingExpiry{Repos: tx, id: f.id})
+	})
+}
+
+func TestSweepSkipsFailingLots(t *testing.T) {
+	now := time.Now()
+	ctx := context.Background()
+	cursor := newTestCursor(now)
+	cursor.SaveUserBalance(ctx, "other", &models.Balance{User: "other", Current: 100})
+	cursor.SaveAccrualLot(ctx, NewLot("other", "4", 100, 100, now.AddDate(0, -13, 0), 12))
+	cursor = &db.Cursor{DBInterface: &failingExpiryDB{DBInterface: cursor.DBInterface, id: 1}}
+
+	assert.NoError(t, NewSweeper(cursor, time.Hour, &ctx).Sweep(now))
+	balance, _ := cursor.GetUserBalance(ctx, "test")
+	assert.Equal(t, float64(300), balance.Current)
+	balance, _ = cursor.GetUserBalance(ctx, "other")
+	assert.Equal(t, float64(0), balance.Current)
+	expired, _ := cursor.GetExpiredLots(ctx, now)
+	assert.Len(t, expired, 1)
+}
@@ -0,0 +1,124 @@
+package jobmanager
+
+import (
+	stderrors "errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nmramorov/gophemart/internal/accrual"
+	"github.com/nmramorov/gophemart/internal/logger"
+)
+
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// Breaker stops the workers from calling the accrual system after
+// Threshold consecutive failures. After OpenTimeout a single probe request
+// is let through: its success closes the circuit, its failure opens it again.
+type Breaker struct {
+	mu          sync.Mutex
+	Threshold   int
+	OpenTimeout time.Duration
+	state       string
+	failures    int
+	openedAt    time.Time
+	probing     bool
+}
+
+func NewBreaker(threshold int, openTimeout time.Duration) *Breaker {
+	if threshold <= 0 {
+		threshold = 1
+	}
+	return &Breaker{
+		Threshold:   threshold,
+		OpenTimeout: openTimeout,
+		state:       BreakerClosed,
+	}
+}
+
+func (b *Breaker) setState(state string) {
+	if b.state == state {
+		return
+	}
+	logger.InfoLog.Printf("Accrual circuit breaker %s -> %s", b.state, state)
+	if state == BreakerOpen {
+		logger.ErrorLog.Printf("Accrual system looks down after %d failures, pausing requests for %s", b.failures, b.OpenTimeout)
+	}
+	b.state = state
+}
+
+// Allow reports whether a request may be sent now.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.OpenTimeout {
+			return false
+		}
+		b.setState(BreakerHalfOpen)
+		b.probing = true
+		return true
+	case BreakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.probing = false
+	b.setState(BreakerClosed)
+}
+
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.probing = false
+	if b.state == BreakerHalfOpen || b.failures >= b.Threshold {
+		b.openedAt = time.Now()
+		b.setState(BreakerOpen)
+	}
+}
+
+func (b *Breaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// RetryIn returns how long until the breaker lets a probe through.
+func (b *Breaker) RetryIn() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != BreakerOpen {
+		return 0
+	}
+	wait := b.OpenTimeout - time.Since(b.openedAt)
+	if wait < 0 {
+		return 0
+	}
+	return wait
+}
+
+// breaks reports whether a failed check says the accrual system is down:
+// the request did not get through or got a 5xx. A 4xx or an answer which
+// could not be parsed still came from a working accrual system.
+func breaks(err error) bool {
+	var response *accrual.ResponseError
+	if stderrors.As(err, &response) {
+		return response.StatusCode >= http.StatusInternalServerError
+	}
+	return true
+}
@@ -0,0 +1,138 @@
+package jobmanager
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	config "github.com/nmramorov/gophemart/internal/configuration"
+	"github.com/nmramorov/gophemart/internal/db"
+	"github.com/nmramorov/gophemart/internal/mocks"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+func TestBreakerStates(t *testing.T) {
+	breaker := NewBreaker(2, 20*time.Millisecond)
+	assert.Equal(t, BreakerClosed, breaker.State())
+
+	breaker.Failure()
+	assert.True(t, breaker.Allow())
+	breaker.Failure()
+	assert.Equal(t, BreakerOpen, breaker.State())
+	assert.False(t, breaker.Allow())
+	assert.Greater(t, breaker.RetryIn(), time.Duration(0))
+
+	time.Sleep(25 * time.Millisecond)
+	assert.True(t, breaker.Allow())
+	assert.Equal(t, BreakerHalfOpen, breaker.State())
+	assert.False(t, breaker.Allow())
+
+	breaker.Failure()
+	assert.Equal(t, BreakerOpen, breaker.State())
+
+	time.Sleep(25 * time.Millisecond)
+	assert.True(t, breaker.Allow())
+	breaker.Success()
+	assert.Equal(t, BreakerClosed, breaker.State())
+	assert.True(t, breaker.Allow())
+}
+
+func TestRunJobDefersWhileOpen(t *testing.T) {
+	calls := 0
+	accrual := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		calls++
+		rw.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer accrual.Close()
+
+	cursor := &db.Cursor{DBInterface: mocks.NewMock()}
+	ctx := context.Background()
+	manager := NewJobmanager(cursor, nil, &config.Config{
+		Accrual:          accrual.URL,
+		Workers:          1,
+		QueueSize:        1,
+		RetryBase:        time.Millisecond,
+		RetryMax:         time.Millisecond,
+		RetryMaxAttempts: 2,
+		BreakerThreshold: 1,
+		BreakerTimeout:   time.Hour,
+	}, &ctx)
+	defer manager.Shutdown()
+	cursor.SaveOrder(ctx, &models.Order{Number: "12345678903", Username: "test", Status: models.OrderNew})
+	assert.NoError(t, manager.AddJob(ctx, "12345678903", "test"))
+
+	received := make(chan *Job, 1)
+	go func() {
+		for job := range manager.Jobs {
+			received <- job
+		}
+	}()
+
+	assert.NoError(t, manager.Dispatch(ctx))
+	manager.RunJob(ctx, <-received)
+	assert.Equal(t, BreakerOpen, manager.Breaker.State())
+
+	time.Sleep(5 * time.Millisecond)
+	assert.NoError(t, manager.Dispatch(ctx))
+	job := <-received
+	manager.RunJob(ctx, job)
+
+	// The open circuit defers the job instead of burning its last attempt.
+	assert.Equal(t, 1, calls)
+	order, _ := cursor.GetOrder(ctx, "test", "12345678903")
+	assert.Equal(t, models.OrderNew, order.Status)
+	jobs, _ := cursor.ClaimJobs(ctx, "test", time.Now().Add(2*time.Hour), time.Now(), 10, nil, true)
+	assert.Len(t, jobs, 1)
+	assert.Equal(t, 1, jobs[0].Failures)
+}
+
+func TestBreakerCountsOutages(t *testing.T) {
+	accrual := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/orders/12345678903":
+			rw.WriteHeader(http.StatusBadRequest)
+		case "/api/orders/79927398713":
+			rw.Header().Set("Content-Type", "application/json")
+			rw.Write([]byte(`{"order": `))
+		default:
+			rw.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer accrual.Close()
+
+	cursor := &db.Cursor{DBInterface: mocks.NewMock()}
+	ctx := context.Background()
+	manager := NewJobmanager(cursor, nil, &config.Config{
+		Accrual:          accrual.URL,
+		Workers:          1,
+		QueueSize:        3,
+		RetryBase:        time.Millisecond,
+		RetryMax:         time.Millisecond,
+		RetryMaxAttempts: 5,
+		BreakerThreshold: 1,
+		BreakerTimeout:   time.Hour,
+	}, &ctx)
+	defer manager.Shutdown()
+	for _, number := range []string{"12345678903", "79927398713", "4561261212345467"} {
+		cursor.SaveOrder(ctx, &models.Order{Number: number, Username: "test", Status: models.OrderNew})
+		assert.NoError(t, manager.AddJob(ctx, number, "test"))
+	}
+	assert.NoError(t, manager.Dispatch(ctx))
+	jobs := map[string]*Job{}
+	for i := 0; i < 3; i++ {
+		job := <-manager.Jobs
+		jobs[job.orderNumber] = job
+	}
+
+	// Rejected requests and malformed answers do not open the circuit.
+	manager.RunJob(ctx, jobs["12345678903"])
+	manager.RunJob(ctx, jobs["79927398713"])
+	assert.Equal(t, BreakerClosed, manager.Breaker.State())
+
+	manager.RunJob(ctx, jobs["4561261212345467"])
+	assert.Equal(t, BreakerOpen, manager.Breaker.State())
+}
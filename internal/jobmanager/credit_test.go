@@ -0,0 +1,114 @@
+package jobmanager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nmramorov/gophemart/internal/accrual"
+	config "github.com/nmramorov/gophemart/internal/configuration"
+	"github.com/nmramorov/gophemart/internal/db"
+	"github.com/nmramorov/gophemart/internal/errors"
+	"github.com/nmramorov/gophemart/internal/mocks"
+	"github.com/nmramorov/gophemart/internal/models"
+	"github.com/nmramorov/gophemart/internal/tiers"
+)
+
+func TestApplyCreditsOnce(t *testing.T) {
+	cursor := &db.Cursor{DBInterface: mocks.NewMock()}
+	ctx := context.Background()
+	first := NewJobmanager(cursor, nil, &config.Config{PointsTTL: 12, InstanceID: "a"}, &ctx)
+	defer first.Shutdown()
+	second := NewJobmanager(cursor, nil, &config.Config{PointsTTL: 12, InstanceID: "b"}, &ctx)
+	defer second.Shutdown()
+
+	cursor.SaveUserBalance(ctx, "test", &models.Balance{Current: 100})
+	cursor.SaveOrder(ctx, &models.Order{Number: "12345678903", Username: "test", Status: models.OrderNew})
+	assert.NoError(t, first.AddJob(ctx, "12345678903", "test"))
+
+	result := &accrual.Result{Order: "12345678903", Status: accrual.StatusProcessed, Accrual: 50}
+	job := &Job{orderNumber: "12345678903", username: "test", attempts: 1, createdAt: time.Now()}
+	applied, err := first.Apply(ctx, job, result)
+	assert.NoError(t, err)
+	assert.True(t, applied)
+	// A duplicate job on another replica must not credit the order again.
+	applied, err = second.Apply(ctx, job, result)
+	assert.NoError(t, err)
+	assert.False(t, applied)
+	applied, err = second.Push(ctx, result)
+	assert.NoError(t, err)
+	assert.False(t, applied)
+
+	// Crediting the order directly, bypassing the job, changes nothing either.
+	credited, err := cursor.CreditOrder(ctx, &models.Credit{Order: "12345678903", User: "test", Status: models.OrderProcessed, Accrual: 50})
+	assert.NoError(t, err)
+	assert.False(t, credited)
+
+	balance, _ := cursor.GetUserBalance(ctx, "test")
+	assert.Equal(t, float64(150), balance.Current)
+	order, _ := cursor.GetOrder(ctx, "test", "12345678903")
+	assert.Equal(t, models.OrderProcessed, order.Status)
+	assert.Equal(t, float64(50), order.Accrual)
+	lots, _ := cursor.GetActiveLots(ctx, "test", time.Now())
+	assert.Len(t, lots, 1)
+	stored, _ := cursor.GetJob(ctx, "12345678903")
+	assert.Equal(t, JobDone, stored.Status)
+	assert.Equal(t, 200, stored.StatusCode)
+}
+
+func TestApplyGuardsStatus(t *testing.T) {
+	cursor := &db.Cursor{DBInterface: mocks.NewMock()}
+	ctx := context.Background()
+	manager := NewJobmanager(cursor, nil, &config.Config{PointsTTL: 12}, &ctx)
+	defer manager.Shutdown()
+
+	cursor.SaveOrder(ctx, &models.Order{Number: "12345678903", Username: "test", Status: models.OrderNew})
+	assert.NoError(t, manager.AddJob(ctx, "12345678903", "test"))
+	job := &Job{orderNumber: "12345678903", username: "test", attempts: 1, createdAt: time.Now()}
+
+	applied, err := manager.Apply(ctx, job, &accrual.Result{Order: "12345678903", Status: "LOST"})
+	assert.ErrorIs(t, err, errors.ErrUnknownStatus)
+	assert.False(t, applied)
+
+	applied, err = manager.Apply(ctx, job, &accrual.Result{Order: "12345678903", Status: accrual.StatusInvalid})
+	assert.NoError(t, err)
+	assert.True(t, applied)
+
+	// A final order never goes back, neither by a late poll nor by failing.
+	stored, err := cursor.UpdateChecks(ctx, manager.Instance, []*models.OrderCheck{{Order: "12345678903", User: "test", Status: models.OrderProcessing}}, time.Now())
+	assert.NoError(t, err)
+	assert.Zero(t, stored)
+	assert.ErrorIs(t, cursor.UpdateOrder(ctx, "test", "12345678903", models.OrderFailed, 0), errors.ErrOrderTransition)
+	assert.ErrorIs(t, cursor.UpdateOrder(ctx, "test", "12345678903", "LOST", 0), errors.ErrUnknownStatus)
+	order, _ := cursor.GetOrder(ctx, "test", "12345678903")
+	assert.Equal(t, models.OrderInvalid, order.Status)
+}
+
+func TestTierUsesBaseAccrual(t *testing.T) {
+	cursor := &db.Cursor{DBInterface: mocks.NewMock()}
+	ctx := context.Background()
+	manager := NewJobmanager(cursor, nil, &config.Config{PointsTTL: 12}, &ctx)
+	defer manager.Shutdown()
+
+	for _, step := range []struct {
+		number  string
+		accrual float64
+	}{{"12345678903", 1000}, {"2377225624", 100}} {
+		cursor.SaveOrder(ctx, &models.Order{Number: step.number, Username: "test", Status: models.OrderNew})
+		assert.NoError(t, manager.AddJob(ctx, step.number, "test"))
+		job := &Job{orderNumber: step.number, username: "test", attempts: 1, createdAt: time.Now()}
+		applied, err := manager.Apply(ctx, job, &accrual.Result{Order: step.number, Status: accrual.StatusProcessed, Accrual: step.accrual})
+		assert.NoError(t, err)
+		assert.True(t, applied)
+	}
+
+	// The second order is multiplied by silver, but only its base accrual
+	// counts towards the tier.
+	balance, _ := cursor.GetUserBalance(ctx, "test")
+	assert.Equal(t, float64(1125), balance.Current)
+	tier, err := tiers.ForUser(ctx, cursor, "test", time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, float64(1100), tier.Accrued)
+}
@@ -0,0 +1,37 @@
+package jobmanager
+
+import (
+	"time"
+
+	"github.com/nmramorov/gophemart/internal/accrual"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+func newAttempt(job *Job, cause error, now time.Time) *models.JobAttempt {
+	attempt := &models.JobAttempt{
+		Order:       job.orderNumber,
+		Attempt:     job.attempts,
+		Error:       cause.Error(),
+		AttemptedAt: now,
+	}
+	if response, ok := cause.(*accrual.ResponseError); ok {
+		attempt.StatusCode = response.StatusCode
+		attempt.ResponseBody = response.Body
+	}
+	return attempt
+}
+
+// newDeadLetter builds the entry of the dead-letter queue where admins can
+// inspect, requeue or discard the job.
+func newDeadLetter(job *Job, attempt *models.JobAttempt) *models.DeadLetter {
+	return &models.DeadLetter{
+		Order:        job.orderNumber,
+		User:         job.username,
+		LastError:    attempt.Error,
+		StatusCode:   attempt.StatusCode,
+		ResponseBody: attempt.ResponseBody,
+		Attempts:     job.attempts,
+		Failures:     job.failures + 1,
+		FailedAt:     attempt.AttemptedAt,
+	}
+}
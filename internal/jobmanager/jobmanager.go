@@ -2,121 +2,643 @@ package jobmanager
 
 import (
 	"context"
+	stderrors "errors"
+	"expvar"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/go-resty/resty/v2"
-
+	"github.com/nmramorov/gophemart/internal/accrual"
+	"github.com/nmramorov/gophemart/internal/campaigns"
+	config "github.com/nmramorov/gophemart/internal/configuration"
 	"github.com/nmramorov/gophemart/internal/db"
 	"github.com/nmramorov/gophemart/internal/errors"
+	"github.com/nmramorov/gophemart/internal/expiration"
 	"github.com/nmramorov/gophemart/internal/logger"
+	"github.com/nmramorov/gophemart/internal/metrics"
 	"github.com/nmramorov/gophemart/internal/models"
+	"github.com/nmramorov/gophemart/internal/notifier"
+	"github.com/nmramorov/gophemart/internal/referrals"
+	"github.com/nmramorov/gophemart/internal/tiers"
 )
 
 type Job struct {
 	orderNumber string
 	username    string
-	cancel      context.CancelFunc
+	attempts    int
+	failures    int
+	createdAt   time.Time
+	provider    string
+}
+
+// Queue is what enqueueing an order needs within the unit of work storing
+// it, the order to route and the job to persist.
+type Queue interface {
+	db.OrderRepository
+	db.JobRepository
+}
+
+// Store is what the jobmanager needs from the database: the jobs and the
+// orders they check, the tiers scaling the accrual and the unit of work
+// crediting it.
+type Store interface {
+	Queue
+	db.TierRepository
+	db.LotRepository
+	db.UnitOfWork
 }
 
 type Jobmanager struct {
-	AccrualURL string
-	Jobs       chan *Job
-	Cursor     *db.Cursor
-	mu         sync.Mutex
-	client     *resty.Client
-	context    context.Context
-	Shutdown     context.CancelFunc
+	AccrualURL    string
+	PointsTTL     int
+	ReferralBonus float64
+	Workers       int
+	JobTimeout    time.Duration
+	Instance      string
+	Lease         time.Duration
+	PushTimeout   time.Duration
+	BatchSize     int
+	Jobs          chan *Job
+	wake          chan struct{}
+	done          chan struct{}
+	busy          int64
+	queued        map[string]int
+	queuedMu      sync.Mutex
+	Cursor        Store
+	Notifier      *notifier.Service
+	mu            sync.Mutex
+	Client        accrual.AccrualClient
+	limiter       *Limiter
+	Breaker       *Breaker
+	Providers     []*Provider
+	Retry         *RetryPolicy
+	Poll          *RetryPolicy
+	checks        []*models.OrderCheck
+	context       context.Context
+	Shutdown      context.CancelFunc
 }
 
-const JOBTIMEOUT = 10
+const (
+	JOBTIMEOUT      = 10
+	HTTPTIMEOUT     = 5
+	JOBPOLLINTERVAL = 1
+	JOBBATCHSIZE    = 100
+	RETRYJITTER     = 0.2
+	JOBLEASE        = 30
+)
+
+const (
+	JobQueued  = "QUEUED"
+	JobRunning = "RUNNING"
+	JobDone    = "DONE"
+	JobFailed  = "FAILED"
+)
+
+// States of the jobs listed by the admin API.
+const (
+	JobStateQueued   = "queued"
+	JobStateRetrying = "retrying"
+	JobStateRunning  = "running"
+	JobStateDead     = "dead"
+	JobStateDone     = "done"
+)
+
+// JobState tells a job waiting for its first check from one waiting for a
+// retry after a failure.
+func JobState(job *models.AccrualJob) string {
+	switch {
+	case job.Status == JobQueued && job.Failures > 0:
+		return JobStateRetrying
+	case job.Status == JobQueued:
+		return JobStateQueued
+	case job.Status == JobRunning:
+		return JobStateRunning
+	case job.Status == JobFailed:
+		return JobStateDead
+	default:
+		return JobStateDone
+	}
+}
 
-func NewJobmanager(cursor *db.Cursor, accrualURL string, parent *context.Context) *Jobmanager {
+func NewJobmanager(cursor Store, notifications *notifier.Service, config *config.Config, parent *context.Context) *Jobmanager {
 	ctx, cancel := context.WithCancel(*parent)
-	return &Jobmanager{
-		AccrualURL: accrualURL,
-		Jobs:       make(chan *Job),
-		Cursor:     cursor,
-		client:     resty.New().SetBaseURL(accrualURL),
-		context:    ctx,
-		Shutdown:     cancel,
+	workers := config.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	jobTimeout := config.JobTimeout
+	if jobTimeout <= 0 {
+		jobTimeout = JOBTIMEOUT * time.Second
+	}
+	httpTimeout := config.AccrualTimeout
+	if httpTimeout <= 0 {
+		httpTimeout = HTTPTIMEOUT * time.Second
+	}
+	if httpTimeout > jobTimeout {
+		httpTimeout = jobTimeout
+	}
+	var pushTimeout time.Duration
+	if config.CallbackSecret != "" {
+		pushTimeout = config.CallbackTimeout
+	}
+	pollBase := JOBPOLLINTERVAL * time.Second
+	if pushTimeout > 0 {
+		pollBase = pushTimeout
+	}
+	pollMax := config.PollMaxInterval
+	if pollMax < pollBase {
+		pollMax = pollBase
+	}
+	lease := config.JobLease
+	if lease <= 0 {
+		lease = JOBLEASE * time.Second
+	}
+	instance := config.InstanceID
+	if instance == "" {
+		instance = newInstanceID()
+	}
+	batchSize := config.PollBatchSize
+	if batchSize <= 0 {
+		batchSize = JOBBATCHSIZE
+	}
+	queueSize := config.QueueSize
+	if queueSize < workers {
+		queueSize = workers
+	}
+	jm := &Jobmanager{
+		AccrualURL:    config.Accrual,
+		PointsTTL:     config.PointsTTL,
+		ReferralBonus: config.ReferralBonus,
+		Workers:       workers,
+		JobTimeout:    jobTimeout,
+		Instance:      instance,
+		Lease:         lease,
+		PushTimeout:   pushTimeout,
+		BatchSize:     batchSize,
+		Jobs:          make(chan *Job, queueSize),
+		wake:          make(chan struct{}, 1),
+		done:          make(chan struct{}),
+		queued:        make(map[string]int),
+		Cursor:        cursor,
+		Notifier:      notifications,
+		Client:        accrual.NewHTTPClient(config.Accrual, httpTimeout),
+		limiter:       NewLimiter(config.AccrualRateLimit),
+		Breaker:       NewBreaker(config.BreakerThreshold, config.BreakerTimeout),
+		Providers:     newProviders(config.Providers, httpTimeout, config.BreakerThreshold, config.BreakerTimeout),
+		Retry: &RetryPolicy{
+			Base:        config.RetryBase,
+			Max:         config.RetryMax,
+			Jitter:      RETRYJITTER,
+			MaxAttempts: config.RetryMaxAttempts,
+			MaxAge:      config.RetryMaxAge,
+		},
+		Poll: &RetryPolicy{
+			Base:   pollBase,
+			Max:    pollMax,
+			Jitter: RETRYJITTER,
+		},
+		context:  ctx,
+		Shutdown: cancel,
+	}
+	metrics.Jobs.Set("queue_depth", expvar.Func(func() interface{} { return len(jm.Jobs) }))
+	metrics.Jobs.Set("queue_capacity", expvar.Func(func() interface{} { return cap(jm.Jobs) }))
+	metrics.Jobs.Set("workers", expvar.Func(func() interface{} { return jm.Workers }))
+	metrics.Jobs.Set("busy_workers", expvar.Func(func() interface{} { return atomic.LoadInt64(&jm.busy) }))
+	metrics.Jobs.Set("worker_utilisation", expvar.Func(func() interface{} { return jm.Utilisation() }))
+	metrics.Accrual.Set("providers", expvar.Func(func() interface{} { return jm.ProviderStates() }))
+	return jm
+}
+
+// Utilisation is the share of workers currently running a job.
+func (jm *Jobmanager) Utilisation() float64 {
+	return float64(atomic.LoadInt64(&jm.busy)) / float64(jm.Workers)
+}
+
+// retry puts the job back with exponential backoff, or fails it for good
+// once the retry policy is exhausted. The attempt is recorded together with
+// the new state of the job.
+func (jm *Jobmanager) retry(ctx context.Context, job *Job, cause error) {
+	failures := job.failures + 1
+	now := time.Now()
+	attempt := newAttempt(job, cause, now)
+	if jm.Retry.Exhausted(failures, job.createdAt, now) {
+		jm.fail(ctx, job, attempt)
+		return
+	}
+	delay := jm.Retry.Backoff(failures)
+	metrics.Accrual.Add("retries", 1)
+	logger.ErrorLog.Printf("Accrual request for order %s failed %d times, retrying in %s: %e", job.orderNumber, failures, delay, cause)
+	err := jm.Cursor.WithTx(ctx, func(tx db.Repos) error {
+		if err := tx.SaveJobAttempt(ctx, attempt); err != nil {
+			return err
+		}
+		return tx.RetryJob(ctx, job.orderNumber, jm.Instance, now.Add(delay), cause.Error(), attempt.StatusCode, now)
+	})
+	if err != nil && !jm.leaseLost(job, err) {
+		logger.ErrorLog.Printf("Error scheduling retry for order %s: %e", job.orderNumber, err)
+	}
+}
+
+// fail moves the order and its job to the terminal FAILED state and
+// leaves a dead letter behind, all in one unit of work. The job is failed
+// first, so nothing is stored once the lease is lost.
+func (jm *Jobmanager) fail(ctx context.Context, job *Job, attempt *models.JobAttempt) {
+	metrics.Accrual.Add("failed", 1)
+	logger.ErrorLog.Printf("Giving up on order %s after %d failures: %s", job.orderNumber, job.failures+1, attempt.Error)
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+	err := jm.Cursor.WithTx(ctx, func(tx db.Repos) error {
+		if err := tx.FailJob(ctx, job.orderNumber, jm.Instance, attempt.Error, attempt.StatusCode, attempt.AttemptedAt); err != nil {
+			return err
+		}
+		if err := tx.SaveJobAttempt(ctx, attempt); err != nil {
+			return err
+		}
+		err := tx.UpdateOrder(ctx, job.username, job.orderNumber, models.OrderFailed, 0)
+		if err != nil && !stderrors.Is(err, errors.ErrOrderTransition) {
+			return err
+		}
+		return tx.SaveDeadLetter(ctx, newDeadLetter(job, attempt))
+	})
+	if err != nil && !jm.leaseLost(job, err) {
+		logger.ErrorLog.Printf("Error failing job for order %s: %e", job.orderNumber, err)
 	}
 }
 
-func (jm *Jobmanager) AskAccrual(url string, number string) (*models.AccrualResponse, int, error) {
-	acc := models.AccrualResponse{}
-	req := jm.client.R().
-		SetResult(&acc).
-		SetPathParam("number", number)
+func (jm *Jobmanager) reschedule(ctx context.Context, job *Job, after time.Duration, reason string) {
+	now := time.Now()
+	err := jm.Cursor.RescheduleJob(ctx, job.orderNumber, jm.Instance, now.Add(after), reason, now)
+	if err != nil && !jm.leaseLost(job, err) {
+		logger.ErrorLog.Printf("Error rescheduling job for order %s: %e", job.orderNumber, err)
+	}
+}
 
-	resp, err := req.Get("/api/orders/{number}")
+// RunJob checks the order once. Orders which are not final yet go back to
+// the queue with the next bulk update, so a worker is never held by a
+// single order. The check has
+// JobTimeout to finish and a timed out check is retried like any other
+// failure. Shutdown interrupts waiting for the rate limiter, but a request
+// already sent is allowed to finish. The queries of the job run with ctx,
+// so a timed out check is still recorded.
+func (jm *Jobmanager) RunJob(ctx context.Context, job *Job) {
+	checkCtx, cancel := context.WithTimeout(ctx, jm.JobTimeout)
+	defer cancel()
+	deadline, _ := checkCtx.Deadline()
+	waitCtx, waitCancel := context.WithDeadline(jm.context, deadline)
+	defer waitCancel()
+	provider := jm.Route(job.orderNumber)
+	if err := provider.Limiter.Wait(waitCtx); err != nil {
+		jm.reschedule(ctx, job, 0, err.Error())
+		return
+	}
+	if !provider.Breaker.Allow() {
+		jm.reschedule(ctx, job, provider.Breaker.RetryIn()+JOBPOLLINTERVAL*time.Second, "accrual circuit of "+provider.Name+" is open")
+		return
+	}
+	result, err := provider.Client.Check(checkCtx, job.orderNumber)
 	if err != nil {
-		logger.ErrorLog.Printf("Error getting order from accrual: %e", err)
-		return nil, 0, err
+		if checkCtx.Err() != nil || stderrors.Is(err, context.DeadlineExceeded) {
+			metrics.Accrual.Add("timeouts", 1)
+		}
+		if breaks(err) {
+			provider.Breaker.Failure()
+		} else {
+			provider.Breaker.Success()
+		}
+		jm.retry(ctx, job, err)
+		return
 	}
-	logger.InfoLog.Printf("Accrual GET status code: %d", resp.StatusCode())
-	if resp.StatusCode() == 429 {
-		return nil, resp.StatusCode(), nil
+	provider.Breaker.Success()
+	if result.Status == accrual.StatusThrottled {
+		provider.Limiter.Throttle(result.RetryAfter, result.RateLimit)
+		jm.reschedule(ctx, job, provider.Limiter.PausedFor(), "too many requests")
+		return
 	}
-	if resp.StatusCode() == 204 {
-		return &models.AccrualResponse{Status: "NEW"}, 204, nil
+	if _, err := jm.Apply(ctx, job, result); err != nil {
+		logger.ErrorLog.Printf("Error applying accrual for order %s: %e", job.orderNumber, err)
+		jm.reschedule(ctx, job, JOBPOLLINTERVAL*time.Second, err.Error())
 	}
-	return &acc, resp.StatusCode(), nil
 }
 
-func (jm *Jobmanager) RunJob(job *Job) {
-	response, statusCode, err := jm.AskAccrual(jm.AccrualURL, job.orderNumber)
+// Apply stores an answer of the accrual system, polled or pushed. A final
+// answer finishes the order and its job and credits the balance, the
+// campaign bonuses and the referral reward in a single unit of work, so it
+// is applied at most once: answers for orders already finished, by this or
+// by another instance, are ignored and reported as not applied.
+func (jm *Jobmanager) Apply(ctx context.Context, job *Job, result *accrual.Result) (bool, error) {
+	status, err := result.OrderStatus()
+	if err != nil {
+		metrics.Accrual.Add("unknown_status", 1)
+		logger.ErrorLog.Printf("Rejecting accrual answer for order %s: %e", job.orderNumber, err)
+		return false, err
+	}
+	response := result.Response()
+	base := response.Accrual
+	jm.mu.Lock()
+	stored, err := jm.Cursor.GetJob(ctx, job.orderNumber)
 	if err != nil {
-		job.cancel()
+		jm.mu.Unlock()
+		return false, err
+	}
+	if stored == nil || stored.Status == JobDone || stored.Status == JobFailed {
+		jm.mu.Unlock()
+		return false, nil
 	}
-	if statusCode == 429 {
-		time.Sleep(time.Second)
+	if !result.Final() {
+		jm.check(job, result, status)
+		jm.mu.Unlock()
+		return true, nil
 	}
-	for response.Status != "INVALID" && response.Status != "PROCESSED" {
-		response, statusCode, err = jm.AskAccrual(jm.AccrualURL, job.orderNumber)
+	if status == models.OrderProcessed {
+		tier, err := tiers.ForUser(ctx, jm.Cursor, job.username, time.Now())
 		if err != nil {
-			job.cancel()
-		}
-		if statusCode == 429 {
-			time.Sleep(time.Second)
-			continue
+			logger.ErrorLog.Printf("Error getting tier for user %s: %e", job.username, err)
+		} else {
+			response.Accrual *= tier.Multiplier
 		}
-		jm.mu.Lock()
-		jm.Cursor.UpdateOrder(job.username, response)
-		jm.mu.Unlock()
 	}
-	jm.mu.Lock()
-	jm.Cursor.UpdateOrder(job.username, response)
-	jm.Cursor.UpdateUserBalance(job.username, &models.Balance{
-		Current:   response.Accrual,
-		Withdrawn: 0.0,
+	now := time.Now()
+	credit := &models.Credit{
+		Order:      job.orderNumber,
+		User:       job.username,
+		Status:     status,
+		Accrual:    response.Accrual,
+		StatusCode: result.StatusCode(),
+		CreditedAt: now,
+	}
+	if status == models.OrderProcessed && response.Accrual > 0 {
+		credit.Lot = expiration.NewLot(job.username, job.orderNumber, response.Accrual, base, now, jm.PointsTTL)
+	}
+	credited := false
+	err = jm.Cursor.WithTx(ctx, func(tx db.Repos) error {
+		var err error
+		credited, err = tx.CreditOrder(ctx, credit)
+		if err != nil || !credited || status != models.OrderProcessed || response.Accrual <= 0 {
+			return err
+		}
+		if _, err := campaigns.Apply(ctx, tx, job.username, response, now); err != nil {
+			logger.ErrorLog.Printf("Error applying campaigns to order %s: %e", job.orderNumber, err)
+			return err
+		}
+		if err := referrals.Reward(ctx, tx, job.username, jm.ReferralBonus, now); err != nil {
+			logger.ErrorLog.Printf("Error rewarding referral of %s: %e", job.username, err)
+			return err
+		}
+		return nil
 	})
 	jm.mu.Unlock()
+	if err != nil || !credited {
+		return false, err
+	}
+	jm.notifyFinished(job, response)
 	logger.InfoLog.Println("Job finished")
+	return true, nil
 }
 
-func (jm *Jobmanager) AddJob(orderNumber string, username string) error {
-	_, cancel := context.WithTimeout(jm.context, JOBTIMEOUT*time.Second)
-	jm.Jobs <- &Job{orderNumber: orderNumber, username: username, cancel: cancel}
-	if jm.Jobs == nil {
-		cancel()
-		return errors.ErrJobChannelClosed
+// Push applies an answer the accrual system sent on its own. Orders
+// without a job are unknown to gophermart and are ignored. A callback is
+// stored right away instead of waiting for the next bulk update.
+func (jm *Jobmanager) Push(ctx context.Context, result *accrual.Result) (bool, error) {
+	stored, err := jm.Cursor.GetJob(ctx, result.Order)
+	if err != nil || stored == nil {
+		return false, err
+	}
+	job := &Job{
+		orderNumber: stored.Order,
+		username:    stored.User,
+		attempts:    stored.Attempts,
+		failures:    stored.Failures,
+		createdAt:   stored.CreatedAt,
+	}
+	applied, err := jm.Apply(ctx, job, result)
+	if err != nil || !applied {
+		return applied, err
+	}
+	metrics.Accrual.Add("pushed", 1)
+	if !result.Final() {
+		return true, jm.Flush(ctx)
+	}
+	return true, nil
+}
+
+func (jm *Jobmanager) notifyFinished(job *Job, response *models.AccrualResponse) {
+	kind := notifier.EventOrderProcessed
+	if status, _ := models.ParseAccrualStatus(response.Status); status == models.OrderInvalid {
+		kind = notifier.EventOrderInvalid
+	}
+	jm.Notifier.Notify(&notifier.Event{
+		Kind:     kind,
+		Username: job.username,
+		Data: map[string]interface{}{
+			"number":  job.orderNumber,
+			"accrual": response.Accrual,
+		},
+	})
+}
+
+// AddJob persists a job for the order, so it survives restarts, and wakes
+// the queue poller.
+func (jm *Jobmanager) AddJob(ctx context.Context, orderNumber string, username string) error {
+	err := jm.Cursor.WithTx(ctx, func(tx db.Repos) error {
+		return jm.Enqueue(ctx, tx, orderNumber, username)
+	})
+	if err != nil {
+		return err
+	}
+	if jm.PushTimeout == 0 {
+		jm.Wake()
 	}
 	return nil
 }
 
-func (jm *Jobmanager) ManageJobs(accrualURL string) {
-	var wg sync.WaitGroup
+// Enqueue routes the order to its accrual system and persists a job for it
+// within tx, the unit of work storing the order. The caller wakes the poller
+// once the work is committed. With push mode on the first check waits for
+// PushTimeout, giving the accrual system time to call back.
+func (jm *Jobmanager) Enqueue(ctx context.Context, tx Queue, orderNumber string, username string) error {
+	if jm.context.Err() != nil {
+		return errors.ErrJobChannelClosed
+	}
+	if err := tx.SetOrderProvider(ctx, orderNumber, jm.Route(orderNumber).Name); err != nil {
+		return err
+	}
+	now := time.Now()
+	return tx.EnqueueJob(ctx, orderNumber, username, now.Add(jm.PushTimeout), now)
+}
+
+// Wake asks the queue poller to dispatch without waiting for the next tick.
+func (jm *Jobmanager) Wake() {
 	select {
-	case <-jm.context.Done():
-		close(jm.Jobs)
+	case jm.wake <- struct{}{}:
 	default:
-		for job := range jm.Jobs {
-			wg.Add(1)
-			logger.InfoLog.Printf("Running job for order %s", job.orderNumber)
-			go jm.RunJob(job)
-			wg.Done()
+	}
+}
+
+// Recover puts orders left in NEW or PROCESSING by a previous run back into
+// the queue, including jobs this instance was running when it stopped. Jobs
+// of other instances are only taken over once their lease expired.
+func (jm *Jobmanager) Recover(ctx context.Context) error {
+	requeued, err := jm.Cursor.RequeueJobs(ctx, jm.Instance, time.Now())
+	if err != nil {
+		return err
+	}
+	logger.InfoLog.Printf("Requeued %d unfinished accrual jobs", requeued)
+	return nil
+}
+
+// Dispatch claims the due jobs from the queue, oldest next check first, and
+// hands them to the workers. Jobs are only claimed while the in-memory queue
+// has room and the rate budget of the provider of their order allows
+// checking them, the rest wait in the database.
+func (jm *Jobmanager) Dispatch(ctx context.Context) error {
+	free := cap(jm.Jobs) - len(jm.Jobs)
+	if free <= 0 {
+		metrics.Jobs.Add("backpressure", 1)
+		logger.InfoLog.Printf("Job queue is full (%d jobs), deferring dispatch", len(jm.Jobs))
+		return nil
+	}
+	if free > jm.BatchSize {
+		free = jm.BatchSize
+	}
+	routed := make([]string, 0, len(jm.Providers))
+	providers := make([]*Provider, 0, len(jm.Providers)+1)
+	for _, provider := range jm.Providers {
+		routed = append(routed, provider.Name)
+		providers = append(providers, provider)
+	}
+	providers = append(providers, jm.defaultProvider())
+	now := time.Now()
+	for _, provider := range providers {
+		if free <= 0 {
+			break
 		}
+		limit := free
+		if budget := provider.budget(); budget > 0 && budget-jm.enqueued(provider.Name) < limit {
+			limit = budget - jm.enqueued(provider.Name)
+			if limit <= 0 {
+				metrics.Jobs.Add("over_budget", 1)
+				continue
+			}
+		}
+		// Orders of no configured provider, including the ones routed before
+		// a provider was removed, go to the default accrual system.
+		names, except := []string{provider.Name}, false
+		if provider.Name == DEFAULTPROVIDER {
+			names, except = routed, true
+		}
+		claimed, err := jm.Cursor.ClaimJobs(ctx, jm.Instance, now, now.Add(jm.Lease), limit, names, except)
+		if err != nil {
+			return err
+		}
+		free -= len(claimed)
+		jm.queue(ctx, provider, claimed)
+	}
+	return nil
+}
+
+// queue hands the jobs claimed for the provider to the workers.
+func (jm *Jobmanager) queue(ctx context.Context, provider *Provider, claimed []*models.AccrualJob) {
+	for _, queued := range claimed {
+		job := &Job{
+			orderNumber: queued.Order,
+			username:    queued.User,
+			attempts:    queued.Attempts,
+			failures:    queued.Failures,
+			createdAt:   queued.CreatedAt,
+			provider:    provider.Name,
+		}
+		jm.queuedMu.Lock()
+		jm.queued[provider.Name]++
+		jm.queuedMu.Unlock()
+		select {
+		case jm.Jobs <- job:
+		case <-jm.context.Done():
+			jm.dequeued(job)
+			jm.checkpoint(ctx, job)
+		}
+	}
+}
+
+func (jm *Jobmanager) pollQueue(ctx context.Context) {
+	ticker := time.NewTicker(JOBPOLLINTERVAL * time.Second)
+	defer ticker.Stop()
+	for {
+		if err := jm.Dispatch(ctx); err != nil {
+			logger.ErrorLog.Printf("Error dispatching accrual jobs: %e", err)
+		}
+		select {
+		case <-jm.context.Done():
+			close(jm.Jobs)
+			return
+		case <-ticker.C:
+			jm.flush(ctx)
+		case <-jm.wake:
+			if jm.buffered() >= jm.BatchSize {
+				jm.flush(ctx)
+			}
+		}
+	}
+}
+
+func (jm *Jobmanager) work(ctx context.Context) {
+	for job := range jm.Jobs {
+		jm.dequeued(job)
+		if jm.context.Err() != nil {
+			jm.checkpoint(ctx, job)
+			continue
+		}
+		atomic.AddInt64(&jm.busy, 1)
+		logger.InfoLog.Printf("Running job for order %s", job.orderNumber)
+		jm.RunJob(ctx, job)
+		atomic.AddInt64(&jm.busy, -1)
+		jm.Wake()
+	}
+}
+
+// checkpoint hands a claimed job which was never started back to the
+// queue, so the next run picks it up without waiting for Recover.
+func (jm *Jobmanager) checkpoint(ctx context.Context, job *Job) {
+	jm.reschedule(ctx, job, 0, "shutdown")
+	logger.InfoLog.Printf("Checkpointed job for order %s", job.orderNumber)
+}
+
+// Stop makes the manager refuse new jobs, lets the running workers finish
+// and checkpoints the jobs still waiting in memory. Jobs still running when
+// ctx expires stay RUNNING in the database and are taken over by another
+// instance once their lease expires.
+func (jm *Jobmanager) Stop(ctx context.Context) error {
+	jm.Shutdown()
+	select {
+	case <-jm.done:
+		logger.InfoLog.Println("Jobmanager stopped")
+		return nil
+	case <-ctx.Done():
+		logger.ErrorLog.Printf("Jobmanager did not stop in time, %d jobs still running", atomic.LoadInt64(&jm.busy))
+		return ctx.Err()
+	}
+}
+
+// ManageJobs runs a fixed pool of workers over the job queue and returns
+// once the queue is closed, every worker is done and their checks are
+// stored.
+func (jm *Jobmanager) ManageJobs(accrualURL string) {
+	defer close(jm.done)
+	// The queries outlive jm.context: running jobs finish and the waiting
+	// ones are checkpointed after Shutdown.
+	ctx := context.Background()
+	if err := jm.Recover(ctx); err != nil {
+		logger.ErrorLog.Printf("Error requeueing unfinished jobs: %e", err)
+	}
+	go jm.pollQueue(ctx)
+	go jm.heartbeat(ctx)
+	var wg sync.WaitGroup
+	for i := 0; i < jm.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			jm.work(ctx)
+		}()
 	}
 	wg.Wait()
+	jm.flush(ctx)
 }
@@ -6,13 +6,17 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
 
+	config "github.com/nmramorov/gophemart/internal/configuration"
 	"github.com/nmramorov/gophemart/internal/db"
+	"github.com/nmramorov/gophemart/internal/errors"
 
 	"github.com/nmramorov/gophemart/internal/logger"
 	"github.com/nmramorov/gophemart/internal/mocks"
@@ -31,10 +35,10 @@ func TestJobmanager(t *testing.T) {
 	handler := &TestHandler{
 		chi.NewMux(),
 		cursor,
-		NewJobmanager(cursor, "localhost:8081", &ctx),
+		NewJobmanager(cursor, nil, &config.Config{Accrual: "localhost:8081", PointsTTL: 12, Workers: 2, QueueSize: 10}, &ctx),
 	}
 	ts := httptest.NewServer(handler)
-	handler.Cursor.SaveUserInfo(&models.UserInfo{
+	handler.Cursor.SaveUserInfo(ctx, &models.UserInfo{
 		Username: "test",
 		Password: "test",
 	})
@@ -92,7 +96,7 @@ func TestJobmanager(t *testing.T) {
 		}
 		defer resp.Body.Close()
 		assert.Equal(t, 200, resp.StatusCode)
-		handler.Manager.AddJob("test", order)
+		handler.Manager.AddJob(ctx, "test", order)
 	}
 	time.Sleep(2 * time.Second)
 	request = httptest.NewRequest(http.MethodGet, "http://localhost:8080/api/user/orders", nil)
@@ -109,10 +113,10 @@ func TestJobmanager(t *testing.T) {
 	}
 
 	assert.Equal(t, "11111111", result[0].Number)
-	assert.Equal(t, "PROCESSING", result[0].Status)
+	assert.Equal(t, models.OrderProcessing, result[0].Status)
 
 	assert.Equal(t, "22222222", result[1].Number)
-	assert.Equal(t, "INVALID", result[1].Status)
+	assert.Equal(t, models.OrderInvalid, result[1].Status)
 
 	time.Sleep(2 * time.Second)
 
@@ -130,9 +134,195 @@ func TestJobmanager(t *testing.T) {
 	}
 
 	assert.Equal(t, "11111111", result[0].Number)
-	assert.Equal(t, "PROCESSED", result[0].Status)
+	assert.Equal(t, models.OrderProcessed, result[0].Status)
 	assert.Equal(t, float64(100), result[0].Accrual)
 
 	assert.Equal(t, "22222222", result[1].Number)
-	assert.Equal(t, "INVALID", result[1].Status)
+	assert.Equal(t, models.OrderInvalid, result[1].Status)
+}
+
+func TestDurableQueue(t *testing.T) {
+	ctx := context.Background()
+	cursor := &db.Cursor{DBInterface: mocks.NewMock()}
+	cursor.SaveOrder(ctx, &models.Order{Number: "12345678903", Username: "test", Status: "NEW"})
+	cursor.SaveOrder(ctx, &models.Order{Number: "2377225624", Username: "test", Status: "PROCESSED"})
+	manager := NewJobmanager(cursor, nil, &config.Config{Accrual: "http://localhost:8081", Workers: 1, QueueSize: 10}, &ctx)
+	defer manager.Shutdown()
+
+	assert.NoError(t, manager.Recover(ctx))
+	assert.NoError(t, manager.AddJob(ctx, "79927398713", "test"))
+
+	received := make(chan *Job, 2)
+	go func() {
+		for job := range manager.Jobs {
+			received <- job
+		}
+	}()
+	assert.NoError(t, manager.Dispatch(ctx))
+	assert.NoError(t, manager.Dispatch(ctx))
+
+	dispatched := map[string]int{}
+	for i := 0; i < 2; i++ {
+		job := <-received
+		dispatched[job.orderNumber] = job.attempts
+	}
+	assert.Equal(t, map[string]int{"12345678903": 1, "79927398713": 1}, dispatched)
+	assert.Equal(t, 0, len(received))
+
+	assert.NoError(t, manager.Recover(ctx))
+	assert.NoError(t, manager.Dispatch(ctx))
+	job := <-received
+	assert.Equal(t, "12345678903", job.orderNumber)
+	assert.Equal(t, 2, job.attempts)
+}
+
+func TestWorkerPool(t *testing.T) {
+	var running, maxRunning int64
+	var mu sync.Mutex
+	accrual := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+		time.Sleep(50 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		number := strings.TrimPrefix(r.URL.Path, "/api/orders/")
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(&models.AccrualResponse{Order: number, Status: "INVALID"})
+	}))
+	defer accrual.Close()
+
+	cursor := &db.Cursor{DBInterface: mocks.NewMock()}
+	ctx := context.Background()
+	manager := NewJobmanager(cursor, nil, &config.Config{Accrual: accrual.URL, Workers: 2, QueueSize: 2}, &ctx)
+	orders := []string{"12345678903", "79927398713", "2377225624", "4561261212345467", "49927398716"}
+	for _, order := range orders {
+		cursor.SaveOrder(ctx, &models.Order{Number: order, Username: "test", Status: "NEW"})
+		assert.NoError(t, manager.AddJob(ctx, order, "test"))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		manager.ManageJobs(accrual.URL)
+		close(done)
+	}()
+	assert.Eventually(t, func() bool {
+		found, _ := cursor.GetOrders(ctx, "test")
+		for _, order := range found {
+			if order.Status != "INVALID" {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 20*time.Millisecond)
+	manager.Shutdown()
+	<-done
+
+	assert.LessOrEqual(t, maxRunning, int64(2))
+	assert.Equal(t, float64(0), manager.Utilisation())
+}
+
+func TestStop(t *testing.T) {
+	started := make(chan struct{}, 3)
+	accrual := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		time.Sleep(100 * time.Millisecond)
+		number := strings.TrimPrefix(r.URL.Path, "/api/orders/")
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(&models.AccrualResponse{Order: number, Status: "INVALID"})
+	}))
+	defer accrual.Close()
+
+	cursor := &db.Cursor{DBInterface: mocks.NewMock()}
+	ctx := context.Background()
+	manager := NewJobmanager(cursor, nil, &config.Config{Accrual: accrual.URL, Workers: 1, QueueSize: 3}, &ctx)
+	orders := []string{"12345678903", "79927398713", "2377225624"}
+	for _, order := range orders {
+		cursor.SaveOrder(ctx, &models.Order{Number: order, Username: "test", Status: "NEW"})
+		assert.NoError(t, manager.AddJob(ctx, order, "test"))
+	}
+
+	go manager.ManageJobs(accrual.URL)
+	<-started
+
+	stopCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	assert.NoError(t, manager.Stop(stopCtx))
+	assert.Equal(t, errors.ErrJobChannelClosed, manager.AddJob(ctx, "49927398716", "test"))
+
+	// The running job finished, the ones waiting in memory went back to the queue.
+	assert.Len(t, started, 0)
+	statuses := map[string]models.OrderStatus{}
+	found, _ := cursor.GetOrders(ctx, "test")
+	for _, order := range found {
+		statuses[order.Number] = order.Status
+	}
+	assert.Equal(t, map[string]models.OrderStatus{"12345678903": "INVALID", "79927398713": "NEW", "2377225624": "NEW"}, statuses)
+	queued, _ := cursor.ClaimJobs(ctx, "test", time.Now(), time.Now(), 10, nil, true)
+	assert.Len(t, queued, 2)
+}
+
+func TestRunJobTimeout(t *testing.T) {
+	release := make(chan struct{})
+	accrual := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer accrual.Close()
+	defer close(release)
+
+	cursor := &db.Cursor{DBInterface: mocks.NewMock()}
+	ctx := context.Background()
+	manager := NewJobmanager(cursor, nil, &config.Config{
+		Accrual:          accrual.URL,
+		JobTimeout:       time.Second,
+		AccrualTimeout:   20 * time.Millisecond,
+		RetryBase:        time.Minute,
+		RetryMax:         time.Minute,
+		RetryMaxAttempts: 5,
+		BreakerThreshold: 5,
+	}, &ctx)
+	defer manager.Shutdown()
+	cursor.SaveOrder(ctx, &models.Order{Number: "12345678903", Username: "test", Status: "NEW"})
+	assert.NoError(t, manager.AddJob(ctx, "12345678903", "test"))
+
+	received := make(chan *Job, 1)
+	go func() {
+		for job := range manager.Jobs {
+			received <- job
+		}
+	}()
+	assert.NoError(t, manager.Dispatch(ctx))
+
+	started := time.Now()
+	manager.RunJob(ctx, <-received)
+	assert.Less(t, time.Since(started), 500*time.Millisecond)
+
+	// The timed out check is back in the queue, due after the backoff.
+	jobs, _ := cursor.ClaimJobs(ctx, "test", time.Now().Add(2*time.Minute), time.Now(), 10, nil, true)
+	assert.Len(t, jobs, 1)
+	assert.Equal(t, 1, jobs[0].Failures)
+	assert.True(t, jobs[0].NextRunAt.After(time.Now()))
+	order, _ := cursor.GetOrder(ctx, "test", "12345678903")
+	assert.Equal(t, models.OrderNew, order.Status)
+}
+
+func TestPushModeDefersPolling(t *testing.T) {
+	cursor := &db.Cursor{DBInterface: mocks.NewMock()}
+	ctx := context.Background()
+	manager := NewJobmanager(cursor, nil, &config.Config{CallbackSecret: "secret", CallbackTimeout: time.Minute}, &ctx)
+	defer manager.Shutdown()
+	assert.Equal(t, time.Minute, manager.PushTimeout)
+
+	assert.NoError(t, manager.AddJob(ctx, "12345678903", "test"))
+	claimed, _ := cursor.ClaimJobs(ctx, "test", time.Now(), time.Now(), 10, nil, true)
+	assert.Empty(t, claimed)
+	claimed, _ = cursor.ClaimJobs(ctx, "test", time.Now().Add(2*time.Minute), time.Now(), 10, nil, true)
+	assert.Len(t, claimed, 1)
 }
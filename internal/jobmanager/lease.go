@@ -0,0 +1,63 @@
+package jobmanager
+
+import (
+	"context"
+	stderrors "errors"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nmramorov/gophemart/internal/errors"
+	"github.com/nmramorov/gophemart/internal/logger"
+	"github.com/nmramorov/gophemart/internal/metrics"
+)
+
+// newInstanceID names this process when INSTANCE_ID is not set. The random
+// suffix keeps restarts on the same host apart.
+func newInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "gophermart"
+	}
+	return host + "-" + uuid.NewString()[:8]
+}
+
+// Heartbeat extends the lease of every job this instance holds, so other
+// instances only take over the jobs of an instance which stopped renewing.
+func (jm *Jobmanager) Heartbeat(ctx context.Context) error {
+	renewed, err := jm.Cursor.RenewLeases(ctx, jm.Instance, time.Now().Add(jm.Lease))
+	if err != nil {
+		return err
+	}
+	metrics.Jobs.Add("lease_renewals", renewed)
+	return nil
+}
+
+// heartbeat renews the leases three times per lease until the manager is
+// done, including while the workers finish on shutdown.
+func (jm *Jobmanager) heartbeat(ctx context.Context) {
+	ticker := time.NewTicker(jm.Lease / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-jm.done:
+			return
+		case <-ticker.C:
+			if err := jm.Heartbeat(ctx); err != nil {
+				logger.ErrorLog.Printf("Error renewing job leases of %s: %e", jm.Instance, err)
+			}
+		}
+	}
+}
+
+// leaseLost reports whether err means another instance took the job over
+// while this one was working on it. The job is left to its new owner.
+func (jm *Jobmanager) leaseLost(job *Job, err error) bool {
+	if !stderrors.Is(err, errors.ErrLeaseLost) {
+		return false
+	}
+	metrics.Jobs.Add("lease_lost", 1)
+	logger.InfoLog.Printf("Lost the lease of job for order %s, leaving it to its new owner", job.orderNumber)
+	return true
+}
@@ -0,0 +1,206 @@
+package jobmanager
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nmramorov/gophemart/internal/accrual"
+	config "github.com/nmramorov/gophemart/internal/configuration"
+	"github.com/nmramorov/gophemart/internal/db"
+	"github.com/nmramorov/gophemart/internal/errors"
+	"github.com/nmramorov/gophemart/internal/mocks"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+func TestSeveralInstances(t *testing.T) {
+	testSeveralInstances(t, &db.Cursor{DBInterface: mocks.NewMock()})
+}
+
+func TestSeveralInstancesPostgres(t *testing.T) {
+	testSeveralInstances(t, newPostgresCursor(t))
+}
+
+func testSeveralInstances(t *testing.T, cursor *db.Cursor) {
+	client := accrual.NewScripted()
+	ctx := context.Background()
+	managers := make([]*Jobmanager, 3)
+	received := make([]chan *Job, 3)
+	for i, instance := range []string{"a", "b", "c"} {
+		managers[i] = NewJobmanager(cursor, nil, &config.Config{
+			Workers:    1,
+			QueueSize:  2,
+			PointsTTL:  12,
+			InstanceID: instance,
+			JobLease:   200 * time.Millisecond,
+		}, &ctx)
+		defer managers[i].Shutdown()
+		managers[i].Client = client
+		received[i] = make(chan *Job, 2)
+		go func(manager *Jobmanager, received chan *Job) {
+			for job := range manager.Jobs {
+				received <- job
+			}
+		}(managers[i], received[i])
+	}
+	a, b, c := managers[0], managers[1], managers[2]
+
+	orders := []string{"12345678903", "79927398713", "4561261212345467", "2377225624", "49927398716", "1234567812345670"}
+	for _, number := range orders {
+		client.Script(number, accrual.Step{Result: &accrual.Result{Order: number, Status: accrual.StatusProcessed, Accrual: 10}})
+		cursor.SaveOrder(ctx, &models.Order{Number: number, Username: number, Status: models.OrderNew})
+		assert.NoError(t, a.AddJob(ctx, number, number))
+	}
+
+	claimed := make([][]*Job, 3)
+	owners := map[string]string{}
+	for i, manager := range managers {
+		assert.NoError(t, manager.Dispatch(ctx))
+		for j := 0; j < 2; j++ {
+			job := <-received[i]
+			claimed[i] = append(claimed[i], job)
+			owners[job.orderNumber] = manager.Instance
+		}
+	}
+	assert.Len(t, owners, len(orders))
+	for _, manager := range managers {
+		assert.NoError(t, manager.Dispatch(ctx))
+	}
+	for i := range managers {
+		assert.Len(t, received[i], 0)
+	}
+
+	// b finishes its jobs, c keeps its leases alive, a hangs.
+	for _, job := range claimed[1] {
+		b.RunJob(ctx, job)
+	}
+	time.Sleep(120 * time.Millisecond)
+	assert.NoError(t, c.Heartbeat(ctx))
+	time.Sleep(120 * time.Millisecond)
+
+	assert.NoError(t, b.Dispatch(ctx))
+	for _, stale := range claimed[0] {
+		job := <-received[1]
+		assert.Equal(t, stale.orderNumber, job.orderNumber)
+		b.RunJob(ctx, job)
+	}
+	assert.Len(t, received[1], 0)
+	for _, job := range claimed[2] {
+		c.RunJob(ctx, job)
+	}
+
+	// a wakes up and must not credit the orders b took over again.
+	for _, job := range claimed[0] {
+		applied, err := a.Apply(ctx, job, &accrual.Result{Order: job.orderNumber, Status: accrual.StatusProcessed, Accrual: 10})
+		assert.NoError(t, err)
+		assert.False(t, applied)
+	}
+
+	for _, number := range orders {
+		job, _ := cursor.GetJob(ctx, number)
+		assert.Equal(t, JobDone, job.Status, number)
+		lots, _ := cursor.GetActiveLots(ctx, number, time.Now())
+		assert.Len(t, lots, 1, number)
+	}
+}
+
+func TestConcurrentClaims(t *testing.T) {
+	testConcurrentClaims(t, &db.Cursor{DBInterface: mocks.NewMock()})
+}
+
+func TestConcurrentClaimsPostgres(t *testing.T) {
+	testConcurrentClaims(t, newPostgresCursor(t))
+}
+
+func testConcurrentClaims(t *testing.T, cursor *db.Cursor) {
+	ctx := context.Background()
+	orders := []string{"12345678903", "79927398713", "4561261212345467", "2377225624", "49927398716", "1234567812345670"}
+	for _, number := range orders {
+		cursor.EnqueueJob(ctx, number, "test", time.Now(), time.Now())
+	}
+
+	managers := make([]*Jobmanager, 4)
+	var wg sync.WaitGroup
+	for i := range managers {
+		managers[i] = NewJobmanager(cursor, nil, &config.Config{Workers: 1, QueueSize: len(orders), InstanceID: fmt.Sprint(i)}, &ctx)
+		defer managers[i].Shutdown()
+		wg.Add(1)
+		go func(manager *Jobmanager) {
+			defer wg.Done()
+			assert.NoError(t, manager.Dispatch(ctx))
+		}(managers[i])
+	}
+	wg.Wait()
+
+	claims := map[string]int{}
+	for _, manager := range managers {
+		for len(manager.Jobs) > 0 {
+			job := <-manager.Jobs
+			claims[job.orderNumber]++
+			stored, _ := cursor.GetJob(ctx, job.orderNumber)
+			assert.Equal(t, manager.Instance, stored.Owner, job.orderNumber)
+		}
+	}
+	assert.Len(t, claims, len(orders))
+	for number, count := range claims {
+		assert.Equal(t, 1, count, number)
+	}
+}
+
+func TestLeaseLost(t *testing.T) {
+	testLeaseLost(t, &db.Cursor{DBInterface: mocks.NewMock()})
+}
+
+func TestLeaseLostPostgres(t *testing.T) {
+	testLeaseLost(t, newPostgresCursor(t))
+}
+
+func testLeaseLost(t *testing.T, cursor *db.Cursor) {
+	client := accrual.NewScripted()
+	ctx := context.Background()
+	number := "12345678903"
+	client.Script(number, accrual.Step{Result: &accrual.Result{Order: number, Status: accrual.StatusProcessing}})
+	cursor.SaveOrder(ctx, &models.Order{Number: number, Username: "test", Status: models.OrderNew})
+	a := NewJobmanager(cursor, nil, &config.Config{InstanceID: "a", JobLease: 50 * time.Millisecond}, &ctx)
+	defer a.Shutdown()
+	b := NewJobmanager(cursor, nil, &config.Config{InstanceID: "b", JobLease: time.Minute}, &ctx)
+	defer b.Shutdown()
+	a.Client, b.Client = client, client
+
+	assert.NoError(t, a.AddJob(ctx, number, "test"))
+	assert.NoError(t, a.Dispatch(ctx))
+	stale := <-a.Jobs
+	time.Sleep(60 * time.Millisecond)
+	assert.NoError(t, b.Dispatch(ctx))
+	job := <-b.Jobs
+
+	// a stalled past its lease, none of its late writes may touch the job b
+	// holds now.
+	a.retry(ctx, stale, errors.ErrAccrualResponse)
+	a.fail(ctx, stale, newAttempt(stale, errors.ErrAccrualResponse, time.Now()))
+	a.reschedule(ctx, stale, 0, "shutdown")
+	a.RunJob(ctx, stale)
+	assert.NoError(t, a.Flush(ctx))
+
+	stored, _ := cursor.GetJob(ctx, number)
+	assert.Equal(t, JobRunning, stored.Status)
+	assert.Equal(t, "b", stored.Owner)
+	assert.Equal(t, 0, stored.Failures)
+	attempts, _ := cursor.GetJobAttempts(ctx, number)
+	assert.Empty(t, attempts)
+	letter, _ := cursor.GetDeadLetter(ctx, number)
+	assert.Nil(t, letter)
+	order, _ := cursor.GetOrder(ctx, "test", number)
+	assert.Equal(t, models.OrderNew, order.Status)
+
+	b.RunJob(ctx, job)
+	assert.NoError(t, b.Flush(ctx))
+	stored, _ = cursor.GetJob(ctx, number)
+	assert.Equal(t, JobQueued, stored.Status)
+	order, _ = cursor.GetOrder(ctx, "test", number)
+	assert.Equal(t, models.OrderProcessing, order.Status)
+}
@@ -0,0 +1,144 @@
+package jobmanager
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/nmramorov/gophemart/internal/logger"
+	"github.com/nmramorov/gophemart/internal/metrics"
+)
+
+const (
+	// THROTTLEMININTERVAL spaces the requests after the first throttle
+	// without a known limit.
+	THROTTLEMININTERVAL = 100 * time.Millisecond
+	// THROTTLEMAXINTERVAL keeps repeated throttles from slowing the requests
+	// down below one per minute.
+	THROTTLEMAXINTERVAL = time.Minute
+	// THROTTLERECOVERY is the quiet period after which a slowed down rate
+	// doubles again.
+	THROTTLERECOVERY = time.Minute
+)
+
+// Limiter spaces out the requests of every worker to the accrual system and
+// pauses all of them while the accrual system asks us to back off.
+type Limiter struct {
+	mu          sync.Mutex
+	base        time.Duration
+	limit       time.Duration
+	interval    time.Duration
+	next        time.Time
+	pausedUntil time.Time
+	calmSince   time.Time
+}
+
+// NewLimiter allows perMinute requests per minute, zero means no limit until
+// the accrual system tells us otherwise.
+func NewLimiter(perMinute int) *Limiter {
+	l := &Limiter{}
+	if perMinute > 0 {
+		l.base = time.Minute / time.Duration(perMinute)
+		l.interval = l.base
+	}
+	return l
+}
+
+// Wait blocks until the caller may send the next request.
+func (l *Limiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	at := time.Now()
+	l.recover(at)
+	if l.pausedUntil.After(at) {
+		at = l.pausedUntil
+	}
+	if l.next.After(at) {
+		at = l.next
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := time.Until(at)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Throttle pauses every worker for retryAfter and adapts the request rate to
+// perMinute. Without a known limit the current rate is halved, down to one
+// request per THROTTLEMAXINTERVAL.
+func (l *Limiter) Throttle(retryAfter time.Duration, perMinute int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	until := time.Now().Add(retryAfter)
+	if until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+	switch {
+	case perMinute > 0:
+		l.limit = time.Minute / time.Duration(perMinute)
+		l.interval = l.limit
+	case l.interval == 0:
+		l.interval = THROTTLEMININTERVAL
+	default:
+		l.interval *= 2
+	}
+	if l.interval > THROTTLEMAXINTERVAL {
+		l.interval = THROTTLEMAXINTERVAL
+	}
+	l.calmSince = l.pausedUntil
+	l.next = l.pausedUntil
+	metrics.Accrual.Add("throttled", 1)
+	logger.InfoLog.Printf("Accrual throttled: pausing requests for %s, rate is %.1f requests per minute", retryAfter, l.rate())
+}
+
+// recover doubles the rate again for every THROTTLERECOVERY without a
+// throttle, until it is back at the configured limit or at the one the
+// accrual system announced.
+func (l *Limiter) recover(now time.Time) {
+	target := l.base
+	if l.limit > target {
+		target = l.limit
+	}
+	for l.interval > target && now.Sub(l.calmSince) >= THROTTLERECOVERY {
+		l.interval /= 2
+		if l.interval < target || (target == 0 && l.interval < THROTTLEMININTERVAL) {
+			l.interval = target
+		}
+		l.calmSince = l.calmSince.Add(THROTTLERECOVERY)
+	}
+}
+
+func (l *Limiter) rate() float64 {
+	if l.interval == 0 {
+		return 0
+	}
+	return float64(time.Minute) / float64(l.interval)
+}
+
+// Rate returns the current limit in requests per minute, zero if unlimited.
+func (l *Limiter) Rate() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.recover(time.Now())
+	return l.rate()
+}
+
+// PausedFor returns how long the workers stay paused.
+func (l *Limiter) PausedFor() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	paused := time.Until(l.pausedUntil)
+	if paused < 0 {
+		return 0
+	}
+	return paused
+}
@@ -0,0 +1,85 @@
+package jobmanager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nmramorov/gophemart/internal/accrual"
+	config "github.com/nmramorov/gophemart/internal/configuration"
+	"github.com/nmramorov/gophemart/internal/db"
+	"github.com/nmramorov/gophemart/internal/mocks"
+)
+
+func TestLimiterThrottle(t *testing.T) {
+	limiter := NewLimiter(0)
+	assert.Equal(t, float64(0), limiter.Rate())
+	assert.NoError(t, limiter.Wait(context.Background()))
+
+	limiter.Throttle(100*time.Millisecond, 600)
+	assert.Equal(t, float64(600), limiter.Rate())
+	assert.Greater(t, limiter.PausedFor(), time.Duration(0))
+
+	start := time.Now()
+	assert.NoError(t, limiter.Wait(context.Background()))
+	assert.NoError(t, limiter.Wait(context.Background()))
+	assert.GreaterOrEqual(t, time.Since(start), 190*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	limiter.Throttle(time.Second, 0)
+	assert.Equal(t, float64(300), limiter.Rate())
+	assert.Error(t, limiter.Wait(ctx))
+}
+
+func TestLimiterRecovers(t *testing.T) {
+	limiter := NewLimiter(0)
+	for i := 0; i < 20; i++ {
+		limiter.Throttle(0, 0)
+	}
+	assert.Equal(t, float64(1), limiter.Rate())
+
+	// Every quiet period doubles the rate until it is unlimited again.
+	limiter.calmSince = time.Now().Add(-3 * THROTTLERECOVERY)
+	assert.Equal(t, float64(8), limiter.Rate())
+	limiter.calmSince = time.Now().Add(-20 * THROTTLERECOVERY)
+	assert.Equal(t, float64(0), limiter.Rate())
+
+	// An announced limit is the most it recovers to.
+	limiter = NewLimiter(0)
+	limiter.Throttle(0, 60)
+	limiter.Throttle(0, 0)
+	assert.Equal(t, float64(30), limiter.Rate())
+	limiter.calmSince = time.Now().Add(-5 * THROTTLERECOVERY)
+	assert.Equal(t, float64(60), limiter.Rate())
+}
+
+func TestRunJobThrottled(t *testing.T) {
+	client := accrual.NewScripted().Script("12345678903",
+		accrual.Step{Result: &accrual.Result{Status: accrual.StatusThrottled, RetryAfter: 2 * time.Second, RateLimit: 30}},
+	)
+	cursor := &db.Cursor{DBInterface: mocks.NewMock()}
+	ctx := context.Background()
+	manager := NewJobmanager(cursor, nil, &config.Config{Workers: 1, QueueSize: 1}, &ctx)
+	defer manager.Shutdown()
+	manager.Client = client
+	assert.NoError(t, manager.AddJob(ctx, "12345678903", "test"))
+
+	received := make(chan *Job, 1)
+	go func() {
+		for job := range manager.Jobs {
+			received <- job
+		}
+	}()
+	assert.NoError(t, manager.Dispatch(ctx))
+	manager.RunJob(ctx, <-received)
+
+	assert.Equal(t, 1, client.Calls("12345678903"))
+	assert.Equal(t, float64(30), manager.limiter.Rate())
+	assert.Greater(t, manager.limiter.PausedFor(), time.Second)
+	jobs, _ := cursor.ClaimJobs(ctx, "test", time.Now().Add(3*time.Second), time.Now(), 10, nil, true)
+	assert.Len(t, jobs, 1)
+	assert.Equal(t, 0, jobs[0].Failures)
+}
@@ -0,0 +1,100 @@
+package jobmanager
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/nmramorov/gophemart/internal/accrual"
+	"github.com/nmramorov/gophemart/internal/logger"
+	"github.com/nmramorov/gophemart/internal/metrics"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+// pollAfter is how long a job waits before the next check of an order which
+// is not final yet: the poll interval doubled per check, up to Poll.Max.
+// With push mode on, polling is only a fallback and starts at PushTimeout.
+func (jm *Jobmanager) pollAfter(job *Job) time.Duration {
+	return jm.Poll.Backoff(job.attempts)
+}
+
+// budget is how many checks the rate limit of the provider allows per poll
+// interval, zero if it is not limited. Claiming no more of its jobs than
+// that keeps claimed jobs from waiting for the limiter.
+func (p *Provider) budget() int {
+	rate := p.Limiter.Rate()
+	if rate == 0 {
+		return 0
+	}
+	return int(math.Ceil(rate * JOBPOLLINTERVAL / 60))
+}
+
+// enqueued counts the jobs of the provider waiting in the in-memory queue.
+func (jm *Jobmanager) enqueued(provider string) int {
+	jm.queuedMu.Lock()
+	defer jm.queuedMu.Unlock()
+	return jm.queued[provider]
+}
+
+// dequeued marks the job as taken off the in-memory queue by a worker.
+func (jm *Jobmanager) dequeued(job *Job) *Job {
+	jm.queuedMu.Lock()
+	defer jm.queuedMu.Unlock()
+	if jm.queued[job.provider] > 0 {
+		jm.queued[job.provider]--
+	}
+	return job
+}
+
+// check buffers a poll which did not finish the order. The buffer is
+// stored by Flush on the next tick, so a batch of pending orders costs one
+// statement instead of two per order. Must be called with jm.mu held.
+func (jm *Jobmanager) check(job *Job, result *accrual.Result, status models.OrderStatus) {
+	now := time.Now()
+	jm.checks = append(jm.checks, &models.OrderCheck{
+		Order:       job.orderNumber,
+		User:        job.username,
+		Status:      status,
+		Accrual:     result.Accrual,
+		StatusCode:  result.StatusCode(),
+		CheckedAt:   now,
+		NextCheckAt: now.Add(jm.pollAfter(job)),
+	})
+}
+
+// buffered returns the number of checks waiting for Flush.
+func (jm *Jobmanager) buffered() int {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+	return len(jm.checks)
+}
+
+// Flush stores the buffered checks and puts their jobs back into the queue.
+// On error the checks stay buffered for the next attempt. Checks of jobs
+// finished or taken over by another instance in the meantime are dropped.
+func (jm *Jobmanager) Flush(ctx context.Context) error {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+	if len(jm.checks) == 0 {
+		return nil
+	}
+	stored, err := jm.Cursor.UpdateChecks(ctx, jm.Instance, jm.checks, time.Now())
+	if err != nil {
+		return err
+	}
+	if dropped := int64(len(jm.checks)) - stored; dropped > 0 {
+		metrics.Jobs.Add("dropped_checks", dropped)
+		logger.InfoLog.Printf("Dropped %d checked orders whose jobs were finished or taken over", dropped)
+	}
+	metrics.Jobs.Add("bulk_updates", 1)
+	metrics.Jobs.Add("checked_orders", stored)
+	logger.InfoLog.Printf("Stored %d checked orders", stored)
+	jm.checks = nil
+	return nil
+}
+
+func (jm *Jobmanager) flush(ctx context.Context) {
+	if err := jm.Flush(ctx); err != nil {
+		logger.ErrorLog.Printf("Error storing checked orders: %e", err)
+	}
+}
@@ -0,0 +1,125 @@
+package jobmanager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nmramorov/gophemart/internal/accrual"
+	config "github.com/nmramorov/gophemart/internal/configuration"
+	"github.com/nmramorov/gophemart/internal/db"
+	"github.com/nmramorov/gophemart/internal/mocks"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+func TestFlushChecks(t *testing.T) {
+	cursor := &db.Cursor{DBInterface: mocks.NewMock()}
+	ctx := context.Background()
+	manager := NewJobmanager(cursor, nil, &config.Config{Workers: 1, QueueSize: 2, PollMaxInterval: time.Minute}, &ctx)
+	defer manager.Shutdown()
+	client := accrual.NewScripted()
+	manager.Client = client
+
+	received := make(chan *Job, 2)
+	go func() {
+		for job := range manager.Jobs {
+			received <- job
+		}
+	}()
+	for _, number := range []string{"12345678903", "4561261212345467"} {
+		client.Script(number, accrual.Step{Result: &accrual.Result{Order: number, Status: accrual.StatusProcessing}})
+		cursor.SaveOrder(ctx, &models.Order{Number: number, Username: "test", Status: models.OrderNew})
+		assert.NoError(t, manager.AddJob(ctx, number, "test"))
+	}
+	assert.NoError(t, manager.Dispatch(ctx))
+	first, second := <-received, <-received
+	second.attempts = 4
+	manager.RunJob(ctx, first)
+	manager.RunJob(ctx, second)
+
+	order, _ := cursor.GetOrder(ctx, "test", first.orderNumber)
+	assert.Equal(t, models.OrderNew, order.Status)
+	assert.Equal(t, 2, manager.buffered())
+
+	// a callback finishes the second order before the checks are stored
+	_, err := manager.Push(ctx, &accrual.Result{Order: second.orderNumber, Status: accrual.StatusInvalid})
+	assert.NoError(t, err)
+	assert.NoError(t, manager.Flush(ctx))
+	assert.Equal(t, 0, manager.buffered())
+
+	order, _ = cursor.GetOrder(ctx, "test", first.orderNumber)
+	assert.Equal(t, models.OrderProcessing, order.Status)
+	job, _ := cursor.GetJob(ctx, first.orderNumber)
+	assert.Equal(t, JobQueued, job.Status)
+	assert.True(t, job.NextRunAt.After(time.Now()))
+	assert.True(t, job.NextRunAt.Before(time.Now().Add(2*time.Second)))
+
+	order, _ = cursor.GetOrder(ctx, "test", second.orderNumber)
+	assert.Equal(t, models.OrderInvalid, order.Status)
+	job, _ = cursor.GetJob(ctx, second.orderNumber)
+	assert.Equal(t, JobDone, job.Status)
+}
+
+func TestPollBackoff(t *testing.T) {
+	ctx := context.Background()
+	manager := NewJobmanager(&db.Cursor{DBInterface: mocks.NewMock()}, nil, &config.Config{PollMaxInterval: 10 * time.Second}, &ctx)
+	defer manager.Shutdown()
+	manager.Poll.Jitter = 0
+	assert.Equal(t, time.Second, manager.pollAfter(&Job{attempts: 1}))
+	assert.Equal(t, 4*time.Second, manager.pollAfter(&Job{attempts: 3}))
+	assert.Equal(t, 10*time.Second, manager.pollAfter(&Job{attempts: 20}))
+}
+
+func TestDispatchWithinBudget(t *testing.T) {
+	cursor := &db.Cursor{DBInterface: mocks.NewMock()}
+	ctx := context.Background()
+	manager := NewJobmanager(cursor, nil, &config.Config{QueueSize: 10, AccrualRateLimit: 120}, &ctx)
+	defer manager.Shutdown()
+	assert.Equal(t, 2, manager.Route("1").budget())
+
+	for _, number := range []string{"1", "2", "3", "4", "5"} {
+		assert.NoError(t, manager.AddJob(ctx, number, "test"))
+	}
+	assert.NoError(t, manager.Dispatch(ctx))
+	assert.Len(t, manager.Jobs, 2)
+	assert.NoError(t, manager.Dispatch(ctx))
+	assert.Len(t, manager.Jobs, 2)
+
+	manager.dequeued(<-manager.Jobs)
+	assert.NoError(t, manager.Dispatch(ctx))
+	assert.Len(t, manager.Jobs, 2)
+	claimed, _ := cursor.ClaimJobs(ctx, "test", time.Now(), time.Now(), 10, nil, true)
+	assert.Len(t, claimed, 2)
+}
+
+func TestDispatchWithinProviderBudget(t *testing.T) {
+	cursor := &db.Cursor{DBInterface: mocks.NewMock()}
+	ctx := context.Background()
+	manager := NewJobmanager(cursor, nil, &config.Config{
+		QueueSize: 10,
+		Providers: config.Providers{{Name: "cards", URL: "http://cards", RateLimit: 120, Prefixes: []string{"4"}}},
+	}, &ctx)
+	defer manager.Shutdown()
+
+	for _, number := range []string{"41", "42", "43", "1", "2"} {
+		cursor.SaveOrder(ctx, &models.Order{Number: number, Username: "test", Status: models.OrderNew})
+		assert.NoError(t, manager.AddJob(ctx, number, "test"))
+	}
+	assert.NoError(t, manager.Dispatch(ctx))
+	providers := map[string]int{}
+	for len(manager.Jobs) > 0 {
+		providers[manager.Route((<-manager.Jobs).orderNumber).Name]++
+	}
+	assert.Equal(t, map[string]int{"cards": 2, DEFAULTPROVIDER: 2}, providers)
+
+	states := manager.ProviderStates()
+	assert.Equal(t, float64(120), states["cards"].RateLimit)
+	assert.Equal(t, 2, states["cards"].Queued)
+	assert.Equal(t, float64(0), states[DEFAULTPROVIDER].RateLimit)
+	assert.Equal(t, BreakerClosed, states[DEFAULTPROVIDER].BreakerState)
+
+	assert.NoError(t, manager.Dispatch(ctx))
+	assert.Len(t, manager.Jobs, 0)
+}
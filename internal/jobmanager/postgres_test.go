@@ -0,0 +1,43 @@
+package jobmanager
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	config "github.com/nmramorov/gophemart/internal/configuration"
+	"github.com/nmramorov/gophemart/internal/db"
+)
+
+// newPostgresCursor connects to the database at TEST_DATABASE_URI and
+// empties the tables the jobs write to, so the leases and the row locks of
+// the real queries are exercised. The test is skipped without the variable.
+func newPostgresCursor(t *testing.T) *db.Cursor {
+	uri := os.Getenv("TEST_DATABASE_URI")
+	if uri == "" {
+		t.Skip("TEST_DATABASE_URI is not set")
+	}
+	// Migrations are read relative to the working directory.
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir("../.."); err != nil {
+		t.Fatal(err)
+	}
+	cursor, err := db.GetCursor(&config.Config{DatabaseURI: uri, QueryTimeout: 5 * time.Second})
+	if err := os.Chdir(wd); err != nil {
+		t.Fatal(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cursor.Close)
+	pool := cursor.DBInterface.(*db.DBCursor).Pool
+	if _, err := pool.Exec(context.Background(), `TRUNCATE orders, accrual_jobs, accrual_job_attempts, dead_letters,
+		balances, accrual_lots, expirations, campaign_bonuses, campaigns, referrals;`); err != nil {
+		t.Fatal(err)
+	}
+	return cursor
+}
@@ -0,0 +1,112 @@
+package jobmanager
+
+import (
+	"strings"
+	"time"
+
+	"github.com/nmramorov/gophemart/internal/accrual"
+	config "github.com/nmramorov/gophemart/internal/configuration"
+)
+
+// DEFAULTPROVIDER serves the orders no configured provider claims.
+const DEFAULTPROVIDER = "default"
+
+// Provider is an accrual system with its own client, rate limit and circuit
+// breaker, so a slow or broken provider does not hold back the others.
+type Provider struct {
+	Name    string
+	Client  accrual.AccrualClient
+	Limiter *Limiter
+	Breaker *Breaker
+	rule    *config.Provider
+}
+
+func newProviders(providers config.Providers, timeout time.Duration, threshold int, openTimeout time.Duration) []*Provider {
+	result := make([]*Provider, 0, len(providers))
+	for _, provider := range providers {
+		result = append(result, &Provider{
+			Name:    provider.Name,
+			Client:  accrual.NewHTTPClient(provider.URL, timeout).SetAuth(provider.Token, provider.Username, provider.Password),
+			Limiter: NewLimiter(provider.RateLimit),
+			Breaker: NewBreaker(threshold, openTimeout),
+			rule:    provider,
+		})
+	}
+	return result
+}
+
+// Matches reports whether the order number starts with one of the prefixes
+// or falls into one of the ranges of the provider.
+func (p *Provider) Matches(number string) bool {
+	if p.rule == nil {
+		return false
+	}
+	for _, prefix := range p.rule.Prefixes {
+		if strings.HasPrefix(number, prefix) {
+			return true
+		}
+	}
+	for _, r := range p.rule.Ranges {
+		if compareNumbers(number, r.From) >= 0 && compareNumbers(number, r.To) <= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// compareNumbers compares order numbers of any length as integers.
+func compareNumbers(a, b string) int {
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// Route returns the first provider matching the order number, or the
+// default accrual system configured with ACCRUAL_SYSTEM_ADDRESS.
+func (jm *Jobmanager) Route(number string) *Provider {
+	for _, provider := range jm.Providers {
+		if provider.Matches(number) {
+			return provider
+		}
+	}
+	return jm.defaultProvider()
+}
+
+// ProviderState is the rate limit, pause, circuit breaker and queue of a
+// provider as reported by the metrics.
+type ProviderState struct {
+	RateLimit     float64 `json:"rate_limit"`
+	PausedSeconds float64 `json:"paused_seconds"`
+	BreakerState  string  `json:"breaker_state"`
+	Queued        int     `json:"queued"`
+}
+
+// ProviderStates returns the state of every provider by name, the default
+// accrual system included.
+func (jm *Jobmanager) ProviderStates() map[string]*ProviderState {
+	states := make(map[string]*ProviderState, len(jm.Providers)+1)
+	for _, provider := range append([]*Provider{jm.defaultProvider()}, jm.Providers...) {
+		states[provider.Name] = &ProviderState{
+			RateLimit:     provider.Limiter.Rate(),
+			PausedSeconds: provider.Limiter.PausedFor().Seconds(),
+			BreakerState:  provider.Breaker.State(),
+			Queued:        jm.enqueued(provider.Name),
+		}
+	}
+	return states
+}
+
+func (jm *Jobmanager) defaultProvider() *Provider {
+	return &Provider{
+		Name:    DEFAULTPROVIDER,
+		Client:  jm.Client,
+		Limiter: jm.limiter,
+		Breaker: jm.Breaker,
+	}
+}
@@ -0,0 +1,86 @@
+package jobmanager
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nmramorov/gophemart/internal/accrual"
+	config "github.com/nmramorov/gophemart/internal/configuration"
+	"github.com/nmramorov/gophemart/internal/db"
+	"github.com/nmramorov/gophemart/internal/mocks"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+func TestRoute(t *testing.T) {
+	ctx := context.Background()
+	manager := NewJobmanager(&db.Cursor{DBInterface: mocks.NewMock()}, nil, &config.Config{
+		Accrual: "http://localhost:8081",
+		Providers: config.Providers{
+			{Name: "cards", URL: "http://cards", Prefixes: []string{"4", "51"}},
+			{Name: "legacy", URL: "http://legacy", Ranges: []*config.Range{{From: "1000", To: "99999"}}},
+		},
+	}, &ctx)
+	defer manager.Shutdown()
+
+	tests := []struct {
+		number   string
+		provider string
+	}{
+		{"4111111111111111", "cards"},
+		{"5105105105105100", "cards"},
+		{"5205105105105100", DEFAULTPROVIDER},
+		{"1000", "legacy"},
+		{"0012345", "legacy"},
+		{"99999", "legacy"},
+		{"100000", DEFAULTPROVIDER},
+		{"999", DEFAULTPROVIDER},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.provider, manager.Route(tt.number).Name, tt.number)
+	}
+}
+
+func TestRunJobRecordsProvider(t *testing.T) {
+	cursor := &db.Cursor{DBInterface: mocks.NewMock()}
+	ctx := context.Background()
+	manager := NewJobmanager(cursor, nil, &config.Config{
+		Workers:   1,
+		QueueSize: 1,
+		Providers: config.Providers{{Name: "cards", URL: "http://cards", Prefixes: []string{"4"}}},
+	}, &ctx)
+	defer manager.Shutdown()
+	cards := accrual.NewScripted().Script("4561261212345467",
+		accrual.Step{Result: &accrual.Result{Order: "4561261212345467", Status: accrual.StatusInvalid}},
+	)
+	manager.Providers[0].Client = cards
+	fallback := accrual.NewScripted().Script("12345678903",
+		accrual.Step{Result: &accrual.Result{Order: "12345678903", Status: accrual.StatusInvalid}},
+	)
+	manager.Client = fallback
+
+	received := make(chan *Job, 1)
+	go func() {
+		for job := range manager.Jobs {
+			received <- job
+		}
+	}()
+	for _, number := range []string{"4561261212345467", "12345678903"} {
+		cursor.SaveOrder(ctx, &models.Order{Number: number, Username: "test", Status: models.OrderNew})
+		assert.NoError(t, manager.AddJob(ctx, number, "test"))
+		order, _ := cursor.GetOrder(ctx, "test", number)
+		assert.Equal(t, manager.Route(number).Name, order.Provider)
+		assert.NoError(t, manager.Dispatch(ctx))
+		manager.RunJob(ctx, <-received)
+	}
+
+	assert.Equal(t, 1, cards.Calls("4561261212345467"))
+	assert.Equal(t, 0, cards.Calls("12345678903"))
+	assert.Equal(t, 1, fallback.Calls("12345678903"))
+	order, _ := cursor.GetOrder(ctx, "test", "4561261212345467")
+	assert.Equal(t, "cards", order.Provider)
+	assert.Equal(t, models.OrderInvalid, order.Status)
+	order, _ = cursor.GetOrder(ctx, "test", "12345678903")
+	assert.Equal(t, DEFAULTPROVIDER, order.Provider)
+}
@@ -0,0 +1,45 @@
+package jobmanager
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy decides when a failed accrual request is retried and when the
+// job gives up.
+type RetryPolicy struct {
+	Base        time.Duration
+	Max         time.Duration
+	Jitter      float64
+	MaxAttempts int
+	MaxAge      time.Duration
+}
+
+// Backoff returns the delay before the given failed attempt is retried:
+// Base doubled per attempt, capped at Max and spread by Jitter.
+func (p *RetryPolicy) Backoff(failures int) time.Duration {
+	if failures < 1 {
+		failures = 1
+	}
+	delay := float64(p.Base) * math.Pow(2, float64(failures-1))
+	if p.Max > 0 && delay > float64(p.Max) {
+		delay = float64(p.Max)
+	}
+	if p.Jitter > 0 {
+		delay *= 1 - p.Jitter + 2*p.Jitter*rand.Float64()
+	}
+	return time.Duration(delay)
+}
+
+// Exhausted reports whether a job with so many failures, created at
+// createdAt, must not be retried any more.
+func (p *RetryPolicy) Exhausted(failures int, createdAt time.Time, now time.Time) bool {
+	if p.MaxAttempts > 0 && failures >= p.MaxAttempts {
+		return true
+	}
+	if p.MaxAge > 0 && !createdAt.IsZero() && now.Sub(createdAt) > p.MaxAge {
+		return true
+	}
+	return false
+}
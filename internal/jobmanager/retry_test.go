@@ -0,0 +1,103 @@
+package jobmanager
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	config "github.com/nmramorov/gophemart/internal/configuration"
+	"github.com/nmramorov/gophemart/internal/db"
+	"github.com/nmramorov/gophemart/internal/mocks"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+func TestBackoff(t *testing.T) {
+	policy := &RetryPolicy{Base: time.Second, Max: 10 * time.Second}
+	assert.Equal(t, time.Second, policy.Backoff(1))
+	assert.Equal(t, 2*time.Second, policy.Backoff(2))
+	assert.Equal(t, 8*time.Second, policy.Backoff(4))
+	assert.Equal(t, 10*time.Second, policy.Backoff(10))
+
+	policy.Jitter = 0.2
+	for i := 0; i < 100; i++ {
+		delay := policy.Backoff(2)
+		assert.GreaterOrEqual(t, delay, 1600*time.Millisecond)
+		assert.LessOrEqual(t, delay, 2400*time.Millisecond)
+	}
+}
+
+func TestExhausted(t *testing.T) {
+	now := time.Now()
+	policy := &RetryPolicy{MaxAttempts: 3, MaxAge: time.Hour}
+	assert.False(t, policy.Exhausted(2, now, now))
+	assert.True(t, policy.Exhausted(3, now, now))
+	assert.True(t, policy.Exhausted(1, now.Add(-2*time.Hour), now))
+}
+
+func TestRunJobRetriesAndFails(t *testing.T) {
+	responses := []func(rw http.ResponseWriter){
+		func(rw http.ResponseWriter) { rw.WriteHeader(http.StatusInternalServerError) },
+		func(rw http.ResponseWriter) {
+			rw.Header().Set("Content-Type", "application/json")
+			rw.Write([]byte(`{"order": "12345678903", "status": `))
+		},
+	}
+	calls := 0
+	accrual := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		responses[calls%len(responses)](rw)
+		calls++
+	}))
+	defer accrual.Close()
+
+	cursor := &db.Cursor{DBInterface: mocks.NewMock()}
+	ctx := context.Background()
+	manager := NewJobmanager(cursor, nil, &config.Config{
+		Accrual:          accrual.URL,
+		Workers:          1,
+		QueueSize:        1,
+		RetryBase:        time.Millisecond,
+		RetryMax:         time.Millisecond,
+		RetryMaxAttempts: 2,
+	}, &ctx)
+	defer manager.Shutdown()
+	cursor.SaveOrder(ctx, &models.Order{Number: "12345678903", Username: "test", Status: models.OrderNew})
+	assert.NoError(t, manager.AddJob(ctx, "12345678903", "test"))
+
+	received := make(chan *Job, 1)
+	go func() {
+		for job := range manager.Jobs {
+			received <- job
+		}
+	}()
+
+	assert.NoError(t, manager.Dispatch(ctx))
+	manager.RunJob(ctx, <-received)
+	order, _ := cursor.GetOrder(ctx, "test", "12345678903")
+	assert.Equal(t, models.OrderNew, order.Status)
+
+	time.Sleep(5 * time.Millisecond)
+	assert.NoError(t, manager.Dispatch(ctx))
+	job := <-received
+	assert.Equal(t, 1, job.failures)
+	manager.RunJob(ctx, job)
+
+	order, _ = cursor.GetOrder(ctx, "test", "12345678903")
+	assert.Equal(t, models.OrderFailed, order.Status)
+	assert.Equal(t, 2, calls)
+
+	letter, _ := cursor.GetDeadLetter(ctx, "12345678903")
+	assert.NotNil(t, letter)
+	assert.Equal(t, "test", letter.User)
+	assert.Equal(t, 2, letter.Failures)
+	assert.Equal(t, 200, letter.StatusCode)
+	assert.Equal(t, `{"order": "12345678903", "status":`, letter.ResponseBody)
+	history, _ := cursor.GetJobAttempts(ctx, "12345678903")
+	assert.Len(t, history, 2)
+	assert.Equal(t, 500, history[0].StatusCode)
+	assert.Equal(t, 1, history[0].Attempt)
+	assert.Equal(t, 2, history[1].Attempt)
+}
@@ -0,0 +1,13 @@
+package metrics
+
+import "expvar"
+
+// Jobs holds the jobmanager gauges and counters. Every expvar variable is
+// served by expvar.Handler.
+var Jobs = expvar.NewMap("jobs")
+
+// Accrual holds the accrual client counters and gauges.
+var Accrual = expvar.NewMap("accrual")
+
+// Database holds the connection pool statistics.
+var Database = expvar.NewMap("database")
@@ -1,6 +1,11 @@
 package mocks
 
 import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
 	"github.com/go-chi/chi/v5"
 
 	"github.com/nmramorov/gophemart/internal/db"
@@ -8,13 +13,30 @@ import (
 	"github.com/nmramorov/gophemart/internal/models"
 )
 
+// MockDB keeps everything in memory. mu guards the data, so the mock can
+// be shared by workers, and tx runs the units of work one at a time.
 type MockDB struct {
 	db.DBInterface
+	mu          sync.Mutex
+	tx          sync.Mutex
 	storage     map[string]string
 	sessions    map[string]models.Session
 	orders      map[string][]*models.Order
 	balance     map[string]*models.Balance
 	withdrawals map[string][]*models.Withdrawal
+	lots        []*models.AccrualLot
+	expirations map[string][]*models.Expiration
+	tiers       []*models.Tier
+	campaigns   []*models.Campaign
+	deleted     map[int64]bool
+	bonuses     []*models.CampaignBonus
+	codes       map[string]string
+	referrals   []*models.Referral
+	preferences map[string]*models.NotificationPreferences
+	devices     map[string]time.Time
+	jobs        []*models.AccrualJob
+	attempts    []*models.JobAttempt
+	deadLetters map[string]*models.DeadLetter
 }
 
 type TestHandler struct {
@@ -30,15 +52,31 @@ func NewMock() *MockDB {
 		orders:      make(map[string][]*models.Order),
 		balance:     make(map[string]*models.Balance),
 		withdrawals: make(map[string][]*models.Withdrawal),
+		lots:        make([]*models.AccrualLot, 0),
+		expirations: make(map[string][]*models.Expiration),
+		codes:       make(map[string]string),
+		preferences: make(map[string]*models.NotificationPreferences),
+		devices:     make(map[string]time.Time),
+		deadLetters: make(map[string]*models.DeadLetter),
+		deleted:     make(map[int64]bool),
+		tiers: []*models.Tier{
+			{Name: "bronze", MinAccrual: 0, Multiplier: 1},
+			{Name: "silver", MinAccrual: 1000, Multiplier: 1.25},
+			{Name: "gold", MinAccrual: 5000, Multiplier: 1.5},
+		},
 	}
 }
 
-func (mock *MockDB) SaveSession(id string, session *models.Session) error {
+func (mock *MockDB) SaveSession(ctx context.Context, id string, session *models.Session) error {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
 	mock.sessions[id] = *session
 	return nil
 }
 
-func (mock *MockDB) SaveUserInfo(info *models.UserInfo) error {
+func (mock *MockDB) SaveUserInfo(ctx context.Context, info *models.UserInfo) error {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
 
 	for k := range mock.storage {
 		if k == info.Username {
@@ -50,7 +88,9 @@ func (mock *MockDB) SaveUserInfo(info *models.UserInfo) error {
 	return nil
 }
 
-func (mock *MockDB) GetUserInfo(info *models.UserInfo) (*models.UserInfo, error) {
+func (mock *MockDB) GetUserInfo(ctx context.Context, info *models.UserInfo) (*models.UserInfo, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
 	for k, v := range mock.storage {
 		if k == info.Username {
 			return &models.UserInfo{
@@ -62,7 +102,9 @@ func (mock *MockDB) GetUserInfo(info *models.UserInfo) (*models.UserInfo, error)
 	return nil, errors.ErrValidation
 }
 
-func (mock *MockDB) GetOrder(username string, number string) (*models.Order, error) {
+func (mock *MockDB) GetOrder(ctx context.Context, username string, number string) (*models.Order, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
 	for user, orders := range mock.orders {
 		if user == username {
 			for _, order := range orders {
@@ -76,19 +118,37 @@ func (mock *MockDB) GetOrder(username string, number string) (*models.Order, err
 	return nil, nil
 }
 
-func (mock *MockDB) SaveOrder(order *models.Order) error {
+func (mock *MockDB) SaveOrder(ctx context.Context, order *models.Order) error {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
 	mock.orders[order.Username] = append(mock.orders[order.Username], order)
 	return nil
 }
 
-func (mock *MockDB) GetOrders(username string) ([]*models.Order, error) {
+func (mock *MockDB) GetOrders(ctx context.Context, username string) ([]*models.Order, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
 	if len(mock.orders[username]) == 0 {
 		return nil, nil
 	}
-	return mock.orders[username], nil
+	orders := make([]*models.Order, 0, len(mock.orders[username]))
+	for _, order := range mock.orders[username] {
+		copied := *order
+		if job := mock.findJob(order.Number); job != nil {
+			copied.CheckedAt = job.CheckedAt
+			if job.Status == "QUEUED" || job.Status == "RUNNING" {
+				nextCheck := job.NextRunAt
+				copied.NextCheck = &nextCheck
+			}
+		}
+		orders = append(orders, &copied)
+	}
+	return orders, nil
 }
 
-func (mock *MockDB) GetUsernameByToken(token string) (string, error) {
+func (mock *MockDB) GetUsernameByToken(ctx context.Context, token string) (string, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
 	session, ok := mock.sessions[token]
 	if !ok {
 		return "", errors.ErrValidation
@@ -96,45 +156,87 @@ func (mock *MockDB) GetUsernameByToken(token string) (string, error) {
 	return session.Username, nil
 }
 
-func (mock *MockDB) GetUserBalance(username string) (*models.Balance, error) {
+func (mock *MockDB) GetUserBalance(ctx context.Context, username string) (*models.Balance, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
 	balance, ok := mock.balance[username]
 	if !ok {
-		return nil, errors.ErrValidation
+		return &models.Balance{}, nil
 	}
 	return balance, nil
 }
 
-func (mock *MockDB) UpdateUserBalance(username string, newBalance *models.Balance) (*models.Balance, error) {
+func (mock *MockDB) UpdateUserBalance(ctx context.Context, username string, newBalance *models.Balance) (*models.Balance, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
 	mock.balance[username] = newBalance
 	return newBalance, nil
 }
 
-func (mock *MockDB) GetWithdrawals(username string) ([]*models.Withdrawal, error) {
+func (mock *MockDB) CreditBalance(ctx context.Context, username string, amount float64) error {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	balance, ok := mock.balance[username]
+	if !ok {
+		balance = &models.Balance{User: username}
+	}
+	mock.balance[username] = &models.Balance{
+		User:      username,
+		Current:   balance.Current + amount,
+		Withdrawn: balance.Withdrawn,
+	}
+	return nil
+}
+
+func (mock *MockDB) DebitBalance(ctx context.Context, username string, amount float64) error {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	if balance, ok := mock.balance[username]; ok {
+		mock.balance[username] = &models.Balance{
+			User:      username,
+			Current:   balance.Current - amount,
+			Withdrawn: balance.Withdrawn,
+		}
+	}
+	return nil
+}
+
+func (mock *MockDB) GetWithdrawals(ctx context.Context, username string) ([]*models.Withdrawal, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
 	return mock.withdrawals[username], nil
 }
 
-func (mock *MockDB) SaveWithdrawal(withdrawal *models.Withdrawal) error {
+func (mock *MockDB) SaveWithdrawal(ctx context.Context, withdrawal *models.Withdrawal) error {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	return mock.saveWithdrawal(withdrawal)
+}
+
+func (mock *MockDB) saveWithdrawal(withdrawal *models.Withdrawal) error {
 	mock.withdrawals[withdrawal.User] = append(mock.withdrawals[withdrawal.User], withdrawal)
 	return nil
 }
 
-func (mock *MockDB) UpdateOrder(username string, from *models.AccrualResponse) error {
-	orders := mock.orders[username]
-	for _, order := range orders {
-		if order.Number == from.Order {
-			if from.Status == "REGISTERED" {
-				order.Status = "PROCESSING"
-				break
-			}
-			order.Accrual = from.Accrual
-			order.Status = from.Status
-			break
+func (mock *MockDB) UpdateOrder(ctx context.Context, username string, number string, status models.OrderStatus, accrual float64) error {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	if !status.Valid() {
+		return errors.ErrUnknownStatus
+	}
+	for _, order := range mock.orders[username] {
+		if order.Number == number && order.Status.CanBecome(status) {
+			order.Accrual = accrual
+			order.Status = status
+			return nil
 		}
 	}
-	return nil
+	return errors.ErrOrderTransition
 }
 
-func (mock *MockDB) GetSession(token string) (*models.Session, error) {
+func (mock *MockDB) GetSession(ctx context.Context, token string) (*models.Session, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
 	session, ok := mock.sessions[token]
 	if !ok {
 		return nil, errors.ErrDatabaseSQLQuery
@@ -142,10 +244,859 @@ func (mock *MockDB) GetSession(token string) (*models.Session, error) {
 	return &session, nil
 }
 
-func (mock *MockDB) GetAllOrders() ([]*models.Order, error) {
+func (mock *MockDB) GetAllOrders(ctx context.Context) ([]*models.Order, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
 	result := make([]*models.Order, 0)
 	for _, orders := range mock.orders {
 		result = append(result, orders...)
 	}
 	return result, nil
 }
+
+func (mock *MockDB) SaveUserBalance(ctx context.Context, username string, newBalance *models.Balance) (*models.Balance, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	mock.balance[username] = newBalance
+	return newBalance, nil
+}
+
+func (mock *MockDB) SaveAccrualLot(ctx context.Context, lot *models.AccrualLot) error {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	return mock.saveAccrualLot(lot)
+}
+
+func (mock *MockDB) saveAccrualLot(lot *models.AccrualLot) error {
+	for _, existing := range mock.lots {
+		if existing.Order == lot.Order {
+			return nil
+		}
+	}
+	lot.ID = int64(len(mock.lots) + 1)
+	mock.lots = append(mock.lots, lot)
+	return nil
+}
+
+func (mock *MockDB) GetActiveLots(ctx context.Context, username string, now time.Time) ([]*models.AccrualLot, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	return mock.activeLots(username, now), nil
+}
+
+// LockLots needs no lock of its own, units of work on the mock run one at a
+// time.
+func (mock *MockDB) LockLots(ctx context.Context, username string) ([]*models.AccrualLot, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	result := make([]*models.AccrualLot, 0)
+	for _, lot := range mock.lots {
+		if lot.User == username && lot.Remaining > 0 {
+			copied := *lot
+			result = append(result, &copied)
+		}
+	}
+	return result, nil
+}
+
+func (mock *MockDB) activeLots(username string, now time.Time) []*models.AccrualLot {
+	result := make([]*models.AccrualLot, 0)
+	for _, lot := range mock.lots {
+		if lot.User == username && lot.Remaining > 0 && lot.ExpiresAt.After(now) {
+			copied := *lot
+			result = append(result, &copied)
+		}
+	}
+	return result
+}
+
+func (mock *MockDB) updateLotRemaining(id int64, remaining float64) error {
+	for _, lot := range mock.lots {
+		if lot.ID == id {
+			lot.Remaining = remaining
+			return nil
+		}
+	}
+	return errors.ErrDatabaseSQLQuery
+}
+
+func (mock *MockDB) GetExpiredLots(ctx context.Context, now time.Time) ([]*models.AccrualLot, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	result := make([]*models.AccrualLot, 0)
+	for _, lot := range mock.lots {
+		if lot.Remaining > 0 && !lot.ExpiresAt.After(now) {
+			result = append(result, lot)
+		}
+	}
+	return result, nil
+}
+
+func (mock *MockDB) ExpireLot(ctx context.Context, id int64) (float64, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	for _, lot := range mock.lots {
+		if lot.ID == id {
+			expired := lot.Remaining
+			lot.Remaining = 0
+			return expired, nil
+		}
+	}
+	return 0, nil
+}
+
+func (mock *MockDB) SaveExpiration(ctx context.Context, expiration *models.Expiration) error {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	mock.expirations[expiration.User] = append(mock.expirations[expiration.User], expiration)
+	return nil
+}
+
+func (mock *MockDB) GetTiers(ctx context.Context) ([]*models.Tier, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	return mock.tiers, nil
+}
+
+func (mock *MockDB) GetAccruedSince(ctx context.Context, username string, since time.Time) (float64, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	var accrued float64
+	for _, lot := range mock.lots {
+		if lot.User == username && lot.AccruedAt.After(since) {
+			accrued += lot.Base
+		}
+	}
+	return accrued, nil
+}
+
+func (mock *MockDB) GetCampaigns(ctx context.Context) ([]*models.Campaign, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	result := make([]*models.Campaign, 0)
+	for _, campaign := range mock.campaigns {
+		if !mock.deleted[campaign.ID] {
+			result = append(result, campaign)
+		}
+	}
+	return result, nil
+}
+
+func (mock *MockDB) GetActiveCampaigns(ctx context.Context, now time.Time) ([]*models.Campaign, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	result := make([]*models.Campaign, 0)
+	for _, campaign := range mock.campaigns {
+		if !mock.deleted[campaign.ID] && !campaign.StartsAt.After(now) && campaign.EndsAt.After(now) {
+			result = append(result, campaign)
+		}
+	}
+	return result, nil
+}
+
+func (mock *MockDB) GetCampaign(ctx context.Context, id int64) (*models.Campaign, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	if mock.deleted[id] {
+		return nil, nil
+	}
+	return mock.findCampaign(id)
+}
+
+// findCampaign finds deleted campaigns as well, their bonuses stay in the
+// ledger.
+func (mock *MockDB) findCampaign(id int64) (*models.Campaign, error) {
+	for _, campaign := range mock.campaigns {
+		if campaign.ID == id {
+			return campaign, nil
+		}
+	}
+	return nil, nil
+}
+
+func (mock *MockDB) SaveCampaign(ctx context.Context, campaign *models.Campaign) error {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	campaign.ID = int64(len(mock.campaigns) + 1)
+	mock.campaigns = append(mock.campaigns, campaign)
+	return nil
+}
+
+func (mock *MockDB) UpdateCampaign(ctx context.Context, campaign *models.Campaign) error {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	if mock.deleted[campaign.ID] {
+		return nil
+	}
+	for i, existing := range mock.campaigns {
+		if existing.ID == campaign.ID {
+			mock.campaigns[i] = campaign
+			return nil
+		}
+	}
+	return nil
+}
+
+func (mock *MockDB) DeleteCampaign(ctx context.Context, id int64, now time.Time) error {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	if campaign, _ := mock.findCampaign(id); campaign != nil {
+		mock.deleted[id] = true
+	}
+	return nil
+}
+
+func (mock *MockDB) SaveCampaignBonus(ctx context.Context, bonus *models.CampaignBonus, perUserCap int) (bool, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	count := 0
+	for _, existing := range mock.bonuses {
+		if existing.Order == bonus.Order && existing.CampaignID == bonus.CampaignID {
+			return false, nil
+		}
+		if existing.User == bonus.User && existing.CampaignID == bonus.CampaignID {
+			count++
+		}
+	}
+	if perUserCap > 0 && count >= perUserCap {
+		return false, nil
+	}
+	mock.bonuses = append(mock.bonuses, bonus)
+	return true, nil
+}
+
+func (mock *MockDB) GetOrderBonuses(ctx context.Context, number string) ([]*models.CampaignBonus, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	result := make([]*models.CampaignBonus, 0)
+	for _, bonus := range mock.bonuses {
+		if bonus.Order == number {
+			campaign, _ := mock.findCampaign(bonus.CampaignID)
+			if campaign != nil {
+				bonus.Campaign = campaign.Name
+			}
+			result = append(result, bonus)
+		}
+	}
+	return result, nil
+}
+
+func (mock *MockDB) SaveReferralCode(ctx context.Context, username string, code string) error {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	mock.codes[username] = code
+	return nil
+}
+
+func (mock *MockDB) GetReferralCode(ctx context.Context, username string) (string, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	return mock.codes[username], nil
+}
+
+func (mock *MockDB) GetReferrerByCode(ctx context.Context, code string) (string, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	for username, existing := range mock.codes {
+		if existing == code {
+			return username, nil
+		}
+	}
+	return "", nil
+}
+
+// LockReferrer needs no lock of its own, units of work on the mock run one
+// at a time.
+func (mock *MockDB) LockReferrer(ctx context.Context, referrer string) error {
+	return nil
+}
+
+func (mock *MockDB) SaveReferral(ctx context.Context, referral *models.Referral) error {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	for _, existing := range mock.referrals {
+		if existing.Referee == referral.Referee {
+			return errors.ErrDatabaseSQLQuery
+		}
+	}
+	mock.referrals = append(mock.referrals, referral)
+	return nil
+}
+
+func (mock *MockDB) CountReferrals(ctx context.Context, referrer string) (int, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	count := 0
+	for _, referral := range mock.referrals {
+		if referral.Referrer == referrer && referral.Status != "REJECTED" {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (mock *MockDB) GetReferrals(ctx context.Context, referrer string) ([]*models.Referral, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	result := make([]*models.Referral, 0)
+	for _, referral := range mock.referrals {
+		if referral.Referrer == referrer {
+			result = append(result, referral)
+		}
+	}
+	return result, nil
+}
+
+func (mock *MockDB) GetReferral(ctx context.Context, referee string) (*models.Referral, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	for _, referral := range mock.referrals {
+		if referral.Referee == referee {
+			return referral, nil
+		}
+	}
+	return nil, nil
+}
+
+func (mock *MockDB) CompleteReferral(ctx context.Context, referee string, rewardedAt time.Time) (bool, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	for _, referral := range mock.referrals {
+		if referral.Referee == referee && referral.Status == "PENDING" {
+			referral.Status = "REWARDED"
+			referral.RewardedAt = &rewardedAt
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (mock *MockDB) GetNotificationPreferences(ctx context.Context, username string) (*models.NotificationPreferences, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	p, ok := mock.preferences[username]
+	if !ok {
+		return &models.NotificationPreferences{User: username, Orders: true, Withdrawals: true, Logins: true}, nil
+	}
+	return p, nil
+}
+
+func (mock *MockDB) SaveNotificationPreferences(ctx context.Context, p *models.NotificationPreferences) error {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	mock.preferences[p.User] = p
+	return nil
+}
+
+func (mock *MockDB) HasDevice(ctx context.Context, username string, fingerprint string) (bool, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	_, ok := mock.devices[username+"/"+fingerprint]
+	return ok, nil
+}
+
+func (mock *MockDB) SaveDevice(ctx context.Context, username string, fingerprint string, seenAt time.Time) error {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	mock.devices[username+"/"+fingerprint] = seenAt
+	return nil
+}
+
+func (mock *MockDB) findJob(number string) *models.AccrualJob {
+	for _, job := range mock.jobs {
+		if job.Order == number {
+			return job
+		}
+	}
+	return nil
+}
+
+func (mock *MockDB) EnqueueJob(ctx context.Context, number string, username string, nextRunAt time.Time, now time.Time) error {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	return mock.enqueueJob(number, username, nextRunAt, now)
+}
+
+func (mock *MockDB) enqueueJob(number string, username string, nextRunAt time.Time, now time.Time) error {
+	if mock.findJob(number) != nil {
+		return nil
+	}
+	mock.jobs = append(mock.jobs, &models.AccrualJob{
+		Order:     number,
+		User:      username,
+		Status:    "QUEUED",
+		NextRunAt: nextRunAt,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	return nil
+}
+
+func (mock *MockDB) ClaimJobs(ctx context.Context, owner string, now time.Time, leaseUntil time.Time, limit int, providers []string, except bool) ([]*models.AccrualJob, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	claimed := make([]*models.AccrualJob, 0)
+	for _, job := range mock.jobs {
+		if len(claimed) == limit {
+			break
+		}
+		if mock.routedTo(job.Order, providers) == except {
+			continue
+		}
+		if (job.Status == "QUEUED" && !job.NextRunAt.After(now)) || (job.Status == "RUNNING" && job.LeaseUntil.Before(now)) {
+			job.Status = "RUNNING"
+			job.Attempts++
+			job.Owner = owner
+			job.LeaseUntil = leaseUntil
+			job.UpdatedAt = now
+			copied := *job
+			claimed = append(claimed, &copied)
+		}
+	}
+	return claimed, nil
+}
+
+// routedTo reports whether the order is routed to one of providers.
+func (mock *MockDB) routedTo(number string, providers []string) bool {
+	for _, orders := range mock.orders {
+		for _, order := range orders {
+			if order.Number != number {
+				continue
+			}
+			for _, provider := range providers {
+				if order.Provider == provider {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// leasedJob returns the job if owner holds its lease at now.
+func (mock *MockDB) leasedJob(number string, owner string, now time.Time) *models.AccrualJob {
+	job := mock.findJob(number)
+	if job == nil || job.Status != "RUNNING" || job.Owner != owner || !job.LeaseUntil.After(now) {
+		return nil
+	}
+	return job
+}
+
+func (mock *MockDB) RescheduleJob(ctx context.Context, number string, owner string, nextRunAt time.Time, lastError string, now time.Time) error {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	job := mock.leasedJob(number, owner, now)
+	if job == nil {
+		return errors.ErrLeaseLost
+	}
+	job.Status = "QUEUED"
+	job.NextRunAt = nextRunAt
+	job.LastError = lastError
+	job.UpdatedAt = now
+	return nil
+}
+
+func (mock *MockDB) RetryJob(ctx context.Context, number string, owner string, nextRunAt time.Time, lastError string, statusCode int, now time.Time) error {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	job := mock.leasedJob(number, owner, now)
+	if job == nil {
+		return errors.ErrLeaseLost
+	}
+	job.Status = "QUEUED"
+	job.Failures++
+	job.NextRunAt = nextRunAt
+	job.LastError = lastError
+	job.StatusCode = statusCode
+	job.CheckedAt = &now
+	job.UpdatedAt = now
+	return nil
+}
+
+func (mock *MockDB) FailJob(ctx context.Context, number string, owner string, lastError string, statusCode int, now time.Time) error {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	job := mock.leasedJob(number, owner, now)
+	if job == nil {
+		return errors.ErrLeaseLost
+	}
+	job.Status = "FAILED"
+	job.Failures++
+	job.LastError = lastError
+	job.StatusCode = statusCode
+	job.CheckedAt = &now
+	job.UpdatedAt = now
+	return nil
+}
+
+func (mock *MockDB) RequeueJobs(ctx context.Context, owner string, now time.Time) (int64, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	var requeued int64
+	for _, orders := range mock.orders {
+		for _, order := range orders {
+			if order.Status != "NEW" && order.Status != "PROCESSING" {
+				continue
+			}
+			job := mock.findJob(order.Number)
+			if job == nil {
+				mock.enqueueJob(order.Number, order.Username, now, now)
+				requeued++
+				continue
+			}
+			if job.Status == "QUEUED" || (job.Status == "RUNNING" && (job.Owner == owner || job.LeaseUntil.Before(now))) {
+				job.Status = "QUEUED"
+				job.NextRunAt = now
+				requeued++
+			}
+		}
+	}
+	return requeued, nil
+}
+
+func (mock *MockDB) RenewLeases(ctx context.Context, owner string, leaseUntil time.Time) (int64, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	var renewed int64
+	for _, job := range mock.jobs {
+		if job.Owner == owner && job.Status == "RUNNING" {
+			job.LeaseUntil = leaseUntil
+			renewed++
+		}
+	}
+	return renewed, nil
+}
+
+func (mock *MockDB) SaveJobAttempt(ctx context.Context, attempt *models.JobAttempt) error {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	mock.attempts = append(mock.attempts, attempt)
+	return nil
+}
+
+func (mock *MockDB) GetJobAttempts(ctx context.Context, number string) ([]*models.JobAttempt, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	found := make([]*models.JobAttempt, 0)
+	for _, attempt := range mock.attempts {
+		if attempt.Order == number {
+			found = append(found, attempt)
+		}
+	}
+	return found, nil
+}
+
+func (mock *MockDB) SaveDeadLetter(ctx context.Context, letter *models.DeadLetter) error {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	mock.deadLetters[letter.Order] = letter
+	return nil
+}
+
+func (mock *MockDB) GetDeadLetters(ctx context.Context) ([]*models.DeadLetter, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	found := make([]*models.DeadLetter, 0, len(mock.deadLetters))
+	for _, letter := range mock.deadLetters {
+		found = append(found, letter)
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].FailedAt.After(found[j].FailedAt) })
+	return found, nil
+}
+
+func (mock *MockDB) GetDeadLetter(ctx context.Context, number string) (*models.DeadLetter, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	return mock.deadLetters[number], nil
+}
+
+func (mock *MockDB) DeleteDeadLetter(ctx context.Context, number string) (bool, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	_, ok := mock.deadLetters[number]
+	delete(mock.deadLetters, number)
+	return ok, nil
+}
+
+func (mock *MockDB) ResetJob(ctx context.Context, number string, username string, now time.Time) error {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	job := mock.findJob(number)
+	if job == nil {
+		return mock.enqueueJob(number, username, now, now)
+	}
+	job.Status = "QUEUED"
+	job.Attempts = 0
+	job.Failures = 0
+	job.NextRunAt = now
+	job.LastError = ""
+	job.CreatedAt = now
+	job.UpdatedAt = now
+	return nil
+}
+
+func (mock *MockDB) DeleteJob(ctx context.Context, number string) error {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	for i, job := range mock.jobs {
+		if job.Order == number {
+			mock.jobs = append(mock.jobs[:i], mock.jobs[i+1:]...)
+			break
+		}
+	}
+	attempts := mock.attempts[:0]
+	for _, attempt := range mock.attempts {
+		if attempt.Order != number {
+			attempts = append(attempts, attempt)
+		}
+	}
+	mock.attempts = attempts
+	return nil
+}
+
+func (mock *MockDB) GetJob(ctx context.Context, number string) (*models.AccrualJob, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	job := mock.findJob(number)
+	if job == nil {
+		return nil, nil
+	}
+	copied := *job
+	return &copied, nil
+}
+
+func (mock *MockDB) GetJobs(ctx context.Context, state string, limit int) ([]*models.AccrualJob, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	jobs := make([]*models.AccrualJob, 0)
+	for _, job := range mock.jobs {
+		copied := *job
+		switch {
+		case job.Status == "DONE":
+			continue
+		case job.Status == "QUEUED" && job.Failures > 0:
+			copied.State = "retrying"
+		case job.Status == "QUEUED":
+			copied.State = "queued"
+		case job.Status == "RUNNING":
+			copied.State = "running"
+		default:
+			copied.State = "dead"
+		}
+		if state == "" || state == copied.State {
+			jobs = append(jobs, &copied)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].NextRunAt.Before(jobs[j].NextRunAt) })
+	if len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+	return jobs, nil
+}
+
+func (mock *MockDB) CreditOrder(ctx context.Context, credit *models.Credit) (bool, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	var found *models.Order
+	for _, order := range mock.orders[credit.User] {
+		if order.Number == credit.Order {
+			found = order
+		}
+	}
+	if !credit.Status.Final() {
+		return false, errors.ErrOrderTransition
+	}
+	if found == nil || !found.Status.CanBecome(credit.Status) {
+		return false, nil
+	}
+	found.Status = credit.Status
+	found.Accrual = credit.Accrual
+	if credit.Accrual > 0 {
+		balance, ok := mock.balance[credit.User]
+		if !ok {
+			balance = &models.Balance{User: credit.User}
+			mock.balance[credit.User] = balance
+		}
+		balance.Current += credit.Accrual
+	}
+	if credit.Lot != nil {
+		mock.saveAccrualLot(credit.Lot)
+	}
+	if job := mock.findJob(credit.Order); job != nil && (job.Status == "QUEUED" || job.Status == "RUNNING") {
+		creditedAt := credit.CreditedAt
+		job.Status = "DONE"
+		job.StatusCode = credit.StatusCode
+		job.CheckedAt = &creditedAt
+		job.UpdatedAt = creditedAt
+	}
+	return true, nil
+}
+
+func (mock *MockDB) SetOrderProvider(ctx context.Context, number string, provider string) error {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	for _, orders := range mock.orders {
+		for _, order := range orders {
+			if order.Number == number {
+				order.Provider = provider
+			}
+		}
+	}
+	return nil
+}
+
+func (mock *MockDB) UpdateChecks(ctx context.Context, owner string, checks []*models.OrderCheck, now time.Time) (int64, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	var stored int64
+	for _, check := range checks {
+		job := mock.findJob(check.Order)
+		if job == nil || (job.Status != "QUEUED" && mock.leasedJob(check.Order, owner, now) == nil) {
+			continue
+		}
+		for _, order := range mock.orders[check.User] {
+			if order.Number == check.Order && order.Status.CanBecome(check.Status) {
+				order.Status = check.Status
+				order.Accrual = check.Accrual
+			}
+		}
+		checkedAt := check.CheckedAt
+		job.Status = "QUEUED"
+		job.NextRunAt = check.NextCheckAt
+		job.LastError = ""
+		job.StatusCode = check.StatusCode
+		job.CheckedAt = &checkedAt
+		job.UpdatedAt = now
+		stored++
+	}
+	return stored, nil
+}
+
+func (mock *MockDB) Close() {}
+
+func (mock *MockDB) Withdraw(ctx context.Context, withdrawal *models.Withdrawal, lots []*models.AccrualLot) error {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	balance, ok := mock.balance[withdrawal.User]
+	if !ok || balance.Current < withdrawal.Sum {
+		return errors.ErrInsufficientBalance
+	}
+	mock.balance[withdrawal.User] = &models.Balance{
+		User:      withdrawal.User,
+		Current:   balance.Current - withdrawal.Sum,
+		Withdrawn: balance.Withdrawn + withdrawal.Sum,
+	}
+	for _, lot := range lots {
+		mock.updateLotRemaining(lot.ID, lot.Remaining)
+	}
+	return mock.saveWithdrawal(withdrawal)
+}
+
+// WithTx runs fn on the mock itself and restores every table when fn
+// fails, like the rollback of a real transaction.
+func (mock *MockDB) WithTx(ctx context.Context, fn func(tx db.Repos) error) error {
+	mock.tx.Lock()
+	defer mock.tx.Unlock()
+	mock.mu.Lock()
+	saved := mock.snapshot()
+	mock.mu.Unlock()
+	if err := fn(mock); err != nil {
+		mock.mu.Lock()
+		defer mock.mu.Unlock()
+		mock.restore(saved)
+		return err
+	}
+	return nil
+}
+
+// snapshot copies every table, rows which are changed in place included.
+func (mock *MockDB) snapshot() *MockDB {
+	saved := &MockDB{
+		storage:     make(map[string]string, len(mock.storage)),
+		sessions:    make(map[string]models.Session, len(mock.sessions)),
+		orders:      make(map[string][]*models.Order, len(mock.orders)),
+		balance:     make(map[string]*models.Balance, len(mock.balance)),
+		withdrawals: make(map[string][]*models.Withdrawal, len(mock.withdrawals)),
+		expirations: make(map[string][]*models.Expiration, len(mock.expirations)),
+		codes:       make(map[string]string, len(mock.codes)),
+		preferences: make(map[string]*models.NotificationPreferences, len(mock.preferences)),
+		devices:     make(map[string]time.Time, len(mock.devices)),
+		deadLetters: make(map[string]*models.DeadLetter, len(mock.deadLetters)),
+		deleted:     make(map[int64]bool, len(mock.deleted)),
+		tiers:       mock.tiers,
+		campaigns:   append([]*models.Campaign{}, mock.campaigns...),
+		bonuses:     append([]*models.CampaignBonus{}, mock.bonuses...),
+		attempts:    append([]*models.JobAttempt{}, mock.attempts...),
+	}
+	for k, v := range mock.storage {
+		saved.storage[k] = v
+	}
+	for k, v := range mock.sessions {
+		saved.sessions[k] = v
+	}
+	for k, v := range mock.orders {
+		for _, order := range v {
+			copied := *order
+			saved.orders[k] = append(saved.orders[k], &copied)
+		}
+	}
+	for k, v := range mock.balance {
+		copied := *v
+		saved.balance[k] = &copied
+	}
+	for k, v := range mock.withdrawals {
+		saved.withdrawals[k] = append([]*models.Withdrawal{}, v...)
+	}
+	for k, v := range mock.expirations {
+		saved.expirations[k] = append([]*models.Expiration{}, v...)
+	}
+	for k, v := range mock.codes {
+		saved.codes[k] = v
+	}
+	for k, v := range mock.preferences {
+		saved.preferences[k] = v
+	}
+	for k, v := range mock.devices {
+		saved.devices[k] = v
+	}
+	for k, v := range mock.deadLetters {
+		saved.deadLetters[k] = v
+	}
+	for k, v := range mock.deleted {
+		saved.deleted[k] = v
+	}
+	for _, lot := range mock.lots {
+		copied := *lot
+		saved.lots = append(saved.lots, &copied)
+	}
+	for _, referral := range mock.referrals {
+		copied := *referral
+		saved.referrals = append(saved.referrals, &copied)
+	}
+	for _, job := range mock.jobs {
+		copied := *job
+		saved.jobs = append(saved.jobs, &copied)
+	}
+	return saved
+}
+
+func (mock *MockDB) restore(saved *MockDB) {
+	mock.storage = saved.storage
+	mock.sessions = saved.sessions
+	mock.orders = saved.orders
+	mock.balance = saved.balance
+	mock.withdrawals = saved.withdrawals
+	mock.lots = saved.lots
+	mock.expirations = saved.expirations
+	mock.tiers = saved.tiers
+	mock.campaigns = saved.campaigns
+	mock.deleted = saved.deleted
+	mock.bonuses = saved.bonuses
+	mock.codes = saved.codes
+	mock.referrals = saved.referrals
+	mock.preferences = saved.preferences
+	mock.devices = saved.devices
+	mock.jobs = saved.jobs
+	mock.attempts = saved.attempts
+	mock.deadLetters = saved.deadLetters
+}
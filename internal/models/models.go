@@ -3,8 +3,9 @@ package models
 import "time"
 
 type UserInfo struct {
-	Username string `json:"login"`
-	Password string `json:"password"`
+	Username     string `json:"login"`
+	Password     string `json:"password"`
+	ReferralCode string `json:"referral_code,omitempty"`
 }
 
 type Session struct {
@@ -14,17 +15,22 @@ type Session struct {
 }
 
 type Order struct {
-	Number     string    `json:"number"`
-	Username   string    `json:"-"`
-	Status     string    `json:"status"`
-	Accrual    float64   `json:"accrual,omitempty"`
-	UploadedAt time.Time `json:"uploaded_at"`
+	Number     string           `json:"number"`
+	Username   string           `json:"-"`
+	Status     OrderStatus      `json:"status"`
+	Accrual    float64          `json:"accrual,omitempty"`
+	UploadedAt time.Time        `json:"uploaded_at"`
+	Provider   string           `json:"provider,omitempty"`
+	CheckedAt  *time.Time       `json:"last_checked_at,omitempty"`
+	NextCheck  *time.Time       `json:"next_check_at,omitempty"`
+	Campaigns  []*CampaignBonus `json:"campaigns,omitempty"`
 }
 
 type Balance struct {
 	User      string  `json:"-"`
 	Current   float64 `json:"current"`
 	Withdrawn float64 `json:"withdrawn"`
+	Expiring  float64 `json:"expiring"`
 }
 
 type WithdrawalPost struct {
@@ -44,3 +50,180 @@ type AccrualResponse struct {
 	Status  string  `json:"status"`
 	Accrual float64 `json:"accrual,omitempty"`
 }
+
+type AccrualLot struct {
+	ID     int64
+	User   string
+	Order  string
+	Amount float64
+	// Base is the accrual before the tier multiplier, tiers are computed
+	// from it so a multiplied accrual never lifts its own tier.
+	Base      float64
+	Remaining float64
+	AccruedAt time.Time
+	ExpiresAt time.Time
+}
+
+type Expiration struct {
+	User      string    `json:"-"`
+	Order     string    `json:"order"`
+	Sum       float64   `json:"sum"`
+	ExpiredAt time.Time `json:"expired_at"`
+}
+
+type Tier struct {
+	Name       string  `json:"name"`
+	MinAccrual float64 `json:"min_accrual"`
+	Multiplier float64 `json:"multiplier"`
+}
+
+type TierStatus struct {
+	Tier       string  `json:"tier"`
+	Multiplier float64 `json:"multiplier"`
+	Accrued    float64 `json:"accrued"`
+	NextTier   string  `json:"next_tier,omitempty"`
+	ToNextTier float64 `json:"to_next_tier,omitempty"`
+}
+
+type Campaign struct {
+	ID             int64     `json:"id"`
+	Name           string    `json:"name"`
+	Kind           string    `json:"kind"`
+	Value          float64   `json:"value"`
+	FirstOrderOnly bool      `json:"first_order_only"`
+	PerUserCap     int       `json:"per_user_cap"`
+	StartsAt       time.Time `json:"starts_at"`
+	EndsAt         time.Time `json:"ends_at"`
+}
+
+type CampaignBonus struct {
+	User       string    `json:"-"`
+	Order      string    `json:"-"`
+	CampaignID int64     `json:"campaign_id"`
+	Campaign   string    `json:"campaign"`
+	Amount     float64   `json:"amount"`
+	CreditedAt time.Time `json:"credited_at"`
+}
+
+type Referral struct {
+	Referrer   string     `json:"-"`
+	Referee    string     `json:"login"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RewardedAt *time.Time `json:"rewarded_at,omitempty"`
+}
+
+type ReferralsInfo struct {
+	Code     string      `json:"code"`
+	Invitees []*Referral `json:"invitees"`
+}
+
+type NotificationPreferences struct {
+	User        string `json:"-"`
+	Email       string `json:"email"`
+	Orders      bool   `json:"orders"`
+	Withdrawals bool   `json:"withdrawals"`
+	Logins      bool   `json:"logins"`
+}
+
+type AccrualJob struct {
+	Order      string     `json:"order"`
+	User       string     `json:"-"`
+	Status     string     `json:"status"`
+	Attempts   int        `json:"attempts"`
+	Failures   int        `json:"failures"`
+	NextRunAt  time.Time  `json:"next_run_at"`
+	LastError  string     `json:"last_error,omitempty"`
+	Owner      string     `json:"owner,omitempty"`
+	LeaseUntil time.Time  `json:"lease_until"`
+	StatusCode int        `json:"last_status_code,omitempty"`
+	CheckedAt  *time.Time `json:"last_checked_at,omitempty"`
+	State      string     `json:"state,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// Credit is the final answer of the accrual system for an order. It is
+// stored in a single transaction together with the balance and the lot.
+type Credit struct {
+	Order      string
+	User       string
+	Status     OrderStatus
+	Accrual    float64
+	Lot        *AccrualLot
+	StatusCode int
+	CreditedAt time.Time
+}
+
+// JobInfo is how the admin API shows an accrual job.
+type JobInfo struct {
+	Order       string     `json:"order"`
+	User        string     `json:"login"`
+	State       string     `json:"state"`
+	Attempts    int        `json:"attempts"`
+	Failures    int        `json:"failures"`
+	StatusCode  int        `json:"last_status_code,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	CheckedAt   *time.Time `json:"last_checked_at,omitempty"`
+	NextAttempt *time.Time `json:"next_attempt_at,omitempty"`
+	Owner       string     `json:"owner,omitempty"`
+	Age         float64    `json:"age"`
+}
+
+type JobAttempt struct {
+	Order        string    `json:"-"`
+	Attempt      int       `json:"attempt"`
+	StatusCode   int       `json:"status_code,omitempty"`
+	Error        string    `json:"error"`
+	ResponseBody string    `json:"response_body,omitempty"`
+	AttemptedAt  time.Time `json:"attempted_at"`
+}
+
+type DeadLetter struct {
+	Order        string        `json:"order"`
+	User         string        `json:"login"`
+	LastError    string        `json:"last_error"`
+	StatusCode   int           `json:"status_code,omitempty"`
+	ResponseBody string        `json:"response_body,omitempty"`
+	Attempts     int           `json:"attempts"`
+	Failures     int           `json:"failures"`
+	FailedAt     time.Time     `json:"failed_at"`
+	History      []*JobAttempt `json:"history,omitempty"`
+}
+
+// OrderCheck is a poll which did not finish the order yet, stored in bulk
+// together with the time of the next check.
+type OrderCheck struct {
+	Order       string
+	User        string
+	Status      OrderStatus
+	Accrual     float64
+	StatusCode  int
+	CheckedAt   time.Time
+	NextCheckAt time.Time
+}
+
+type Health struct {
+	Status    string            `json:"status"`
+	Accrual   string            `json:"accrual"`
+	RetryIn   float64           `json:"retry_in,omitempty"`
+	Providers map[string]string `json:"providers,omitempty"`
+}
+
+type CallbackSummary struct {
+	Applied  int `json:"applied"`
+	Ignored  int `json:"ignored"`
+	Rejected int `json:"rejected"`
+}
+
+// PoolStats is a snapshot of the database connection pool.
+type PoolStats struct {
+	TotalConns      int32   `json:"total_conns"`
+	IdleConns       int32   `json:"idle_conns"`
+	AcquiredConns   int32   `json:"acquired_conns"`
+	MaxConns        int32   `json:"max_conns"`
+	AcquireCount    int64   `json:"acquire_count"`
+	AcquireWait     float64 `json:"acquire_wait_seconds"`
+	EmptyAcquires   int64   `json:"empty_acquire_count"`
+	CanceledAcquire int64   `json:"canceled_acquire_count"`
+}
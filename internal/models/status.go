@@ -0,0 +1,89 @@
+package models
+
+import (
+	"fmt"
+
+	"github.com/nmramorov/gophemart/internal/errors"
+)
+
+// OrderStatus is the status of an uploaded order.
+type OrderStatus string
+
+const (
+	OrderNew        OrderStatus = "NEW"
+	OrderProcessing OrderStatus = "PROCESSING"
+	OrderInvalid    OrderStatus = "INVALID"
+	OrderProcessed  OrderStatus = "PROCESSED"
+	OrderFailed     OrderStatus = "FAILED"
+)
+
+// orderStatuses keeps the statuses in a stable order for generated queries.
+var orderStatuses = []OrderStatus{OrderNew, OrderProcessing, OrderInvalid, OrderProcessed, OrderFailed}
+
+// orderTransitions lists the statuses every status may become. INVALID and
+// PROCESSED are final, a FAILED order only goes back to NEW when its dead
+// letter is replayed.
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderNew:        {OrderNew, OrderProcessing, OrderInvalid, OrderProcessed, OrderFailed},
+	OrderProcessing: {OrderProcessing, OrderInvalid, OrderProcessed, OrderFailed},
+	OrderFailed:     {OrderNew},
+	OrderInvalid:    {},
+	OrderProcessed:  {},
+}
+
+// Valid reports whether s is one of the known statuses.
+func (s OrderStatus) Valid() bool {
+	_, ok := orderTransitions[s]
+	return ok
+}
+
+// Final reports whether the accrual system is done with the order.
+func (s OrderStatus) Final() bool {
+	return s == OrderInvalid || s == OrderProcessed
+}
+
+// CanBecome reports whether an order in status s may move to status to.
+func (s OrderStatus) CanBecome(to OrderStatus) bool {
+	for _, next := range orderTransitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Sources returns the statuses an order may have to move to s.
+func (s OrderStatus) Sources() []OrderStatus {
+	sources := []OrderStatus{}
+	for _, from := range orderStatuses {
+		if from.CanBecome(s) {
+			sources = append(sources, from)
+		}
+	}
+	return sources
+}
+
+// OrderTransitions returns every allowed transition as a from, to pair.
+func OrderTransitions() [][2]OrderStatus {
+	pairs := [][2]OrderStatus{}
+	for _, from := range orderStatuses {
+		for _, to := range orderTransitions[from] {
+			pairs = append(pairs, [2]OrderStatus{from, to})
+		}
+	}
+	return pairs
+}
+
+// ParseAccrualStatus maps a status of the accrual system to the status of
+// the order. REGISTERED means the accrual system is processing the order.
+func ParseAccrualStatus(status string) (OrderStatus, error) {
+	switch status {
+	case "REGISTERED", string(OrderProcessing):
+		return OrderProcessing, nil
+	case string(OrderInvalid):
+		return OrderInvalid, nil
+	case string(OrderProcessed):
+		return OrderProcessed, nil
+	}
+	return "", fmt.Errorf("%w: %q", errors.ErrUnknownStatus, status)
+}
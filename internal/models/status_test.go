@@ -0,0 +1,40 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nmramorov/gophemart/internal/errors"
+)
+
+func TestOrderTransitions(t *testing.T) {
+	assert.True(t, OrderNew.CanBecome(OrderProcessing))
+	assert.True(t, OrderProcessing.CanBecome(OrderProcessed))
+	assert.True(t, OrderFailed.CanBecome(OrderNew))
+	assert.False(t, OrderProcessed.CanBecome(OrderProcessing))
+	assert.False(t, OrderInvalid.CanBecome(OrderProcessed))
+	assert.False(t, OrderProcessing.CanBecome(OrderNew))
+	assert.False(t, OrderStatus("LOST").Valid())
+
+	assert.Equal(t, []OrderStatus{OrderNew, OrderProcessing}, OrderProcessed.Sources())
+	assert.Equal(t, []OrderStatus{OrderNew, OrderFailed}, OrderNew.Sources())
+	for _, pair := range OrderTransitions() {
+		assert.False(t, pair[0].Final())
+	}
+}
+
+func TestParseAccrualStatus(t *testing.T) {
+	status, err := ParseAccrualStatus("REGISTERED")
+	assert.NoError(t, err)
+	assert.Equal(t, OrderProcessing, status)
+
+	status, err = ParseAccrualStatus("PROCESSED")
+	assert.NoError(t, err)
+	assert.Equal(t, OrderProcessed, status)
+
+	_, err = ParseAccrualStatus("NEW")
+	assert.ErrorIs(t, err, errors.ErrUnknownStatus)
+	_, err = ParseAccrualStatus("LOST")
+	assert.ErrorIs(t, err, errors.ErrUnknownStatus)
+}
@@ -0,0 +1,216 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"text/template"
+	"time"
+
+	config "github.com/nmramorov/gophemart/internal/configuration"
+	"github.com/nmramorov/gophemart/internal/db"
+	"github.com/nmramorov/gophemart/internal/errors"
+	"github.com/nmramorov/gophemart/internal/logger"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+const (
+	EventOrderProcessed = "order_processed"
+	EventOrderInvalid   = "order_invalid"
+	EventWithdrawal     = "withdrawal"
+	EventNewDevice      = "new_device"
+)
+
+// NOTIFYQUEUE is how many events may wait for delivery before Notify drops
+// new ones.
+const NOTIFYQUEUE = 100
+
+type Event struct {
+	Kind     string
+	Username string
+	Data     map[string]interface{}
+	At       time.Time
+}
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Notifier delivers rendered messages to a sink.
+type Notifier interface {
+	Send(*Message) error
+}
+
+type messageTemplate struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+func newTemplate(kind string, subject string, body string) *messageTemplate {
+	return &messageTemplate{
+		subject: template.Must(template.New(kind + "_subject").Parse(subject)),
+		body:    template.Must(template.New(kind).Parse(body)),
+	}
+}
+
+var templates = map[string]*messageTemplate{
+	EventOrderProcessed: newTemplate(EventOrderProcessed,
+		"Order {{.number}} processed",
+		"Hello, {{.username}}! Order {{.number}} was processed and {{.accrual}} points were credited.\n"),
+	EventOrderInvalid: newTemplate(EventOrderInvalid,
+		"Order {{.number}} rejected",
+		"Hello, {{.username}}! Order {{.number}} was rejected by the accrual system.\n"),
+	EventWithdrawal: newTemplate(EventWithdrawal,
+		"Withdrawal for order {{.order}}",
+		"Hello, {{.username}}! {{.sum}} points were withdrawn for order {{.order}}.\n"),
+	EventNewDevice: newTemplate(EventNewDevice,
+		"New login",
+		"Hello, {{.username}}! Your account was accessed from a new device: {{.device}}.\n"),
+}
+
+// Service renders events into messages and hands them to the sink when the
+// user's preferences allow it. A nil Service drops every event.
+type Service struct {
+	Cursor db.NotificationRepository
+	Sink   Notifier
+	mu     sync.Mutex
+	queue  chan *Event
+	closed bool
+	done   chan struct{}
+}
+
+// NewService starts the worker delivering the queued events. Close stops it.
+func NewService(cursor db.NotificationRepository, sink Notifier) *Service {
+	s := &Service{
+		Cursor: cursor,
+		Sink:   sink,
+		queue:  make(chan *Event, NOTIFYQUEUE),
+		done:   make(chan struct{}),
+	}
+	go s.deliverQueued()
+	return s
+}
+
+func (s *Service) deliverQueued() {
+	defer close(s.done)
+	for event := range s.queue {
+		if err := s.Deliver(context.Background(), event); err != nil {
+			logger.ErrorLog.Printf("Error delivering %s notification to %s: %e", event.Kind, event.Username, err)
+		}
+	}
+}
+
+func enabled(preferences *models.NotificationPreferences, kind string) bool {
+	switch kind {
+	case EventOrderProcessed, EventOrderInvalid:
+		return preferences.Orders
+	case EventWithdrawal:
+		return preferences.Withdrawals
+	case EventNewDevice:
+		return preferences.Logins
+	}
+	return false
+}
+
+func Render(event *Event, to string) (*Message, error) {
+	tmpl, ok := templates[event.Kind]
+	if !ok {
+		return nil, errors.ErrUnknownEvent
+	}
+	data := map[string]interface{}{"username": event.Username}
+	for k, v := range event.Data {
+		data[k] = v
+	}
+	subject := bytes.NewBuffer([]byte{})
+	if err := tmpl.subject.Execute(subject, data); err != nil {
+		return nil, err
+	}
+	body := bytes.NewBuffer([]byte{})
+	if err := tmpl.body.Execute(body, data); err != nil {
+		return nil, err
+	}
+	return &Message{
+		To:      to,
+		Subject: subject.String(),
+		Body:    body.String(),
+	}, nil
+}
+
+// Deliver renders and sends event synchronously.
+func (s *Service) Deliver(ctx context.Context, event *Event) error {
+	if s == nil {
+		return nil
+	}
+	preferences, err := s.Cursor.GetNotificationPreferences(ctx, event.Username)
+	if err != nil {
+		return err
+	}
+	if !enabled(preferences, event.Kind) {
+		return nil
+	}
+	to := preferences.Email
+	if to == "" {
+		to = event.Username
+	}
+	message, err := Render(event, to)
+	if err != nil {
+		return err
+	}
+	return s.Sink.Send(message)
+}
+
+// Notify queues event for delivery in the background so callers are never
+// slowed down by the sink. Events beyond NOTIFYQUEUE waiting ones and events
+// after Close are dropped.
+func (s *Service) Notify(event *Event) {
+	if s == nil {
+		return
+	}
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		logger.ErrorLog.Printf("Notifier is closed, dropping %s notification to %s", event.Kind, event.Username)
+		return
+	}
+	select {
+	case s.queue <- event:
+	default:
+		logger.ErrorLog.Printf("Notification queue is full, dropping %s notification to %s", event.Kind, event.Username)
+	}
+}
+
+// Close stops accepting events and waits until the queued ones are
+// delivered or ctx expires.
+func (s *Service) Close(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// NewSink picks the configured sink: "smtp", "file" or "log" by default.
+func NewSink(config *config.Config) Notifier {
+	switch config.Notifier {
+	case "smtp":
+		return NewSMTPSink(config.SMTPAddress, config.SMTPFrom, config.SMTPUsername, config.SMTPPassword)
+	case "file":
+		return NewFileSink(config.NotifyFile)
+	}
+	return LogSink{}
+}
@@ -0,0 +1,88 @@
+package notifier
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nmramorov/gophemart/internal/db"
+	"github.com/nmramorov/gophemart/internal/mocks"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+type recordingSink struct {
+	messages []*Message
+}
+
+func (r *recordingSink) Send(message *Message) error {
+	r.messages = append(r.messages, message)
+	return nil
+}
+
+func TestDeliver(t *testing.T) {
+	ctx := context.Background()
+	cursor := &db.Cursor{DBInterface: mocks.NewMock()}
+	sink := &recordingSink{}
+	service := NewService(cursor, sink)
+
+	err := service.Deliver(ctx, &Event{
+		Kind:     EventOrderProcessed,
+		Username: "test",
+		Data:     map[string]interface{}{"number": "12345678903", "accrual": 500},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(sink.messages))
+	assert.Equal(t, "test", sink.messages[0].To)
+	assert.Equal(t, "Order 12345678903 processed", sink.messages[0].Subject)
+	assert.Contains(t, sink.messages[0].Body, "500 points")
+
+	cursor.SaveNotificationPreferences(ctx, &models.NotificationPreferences{
+		User:   "test",
+		Email:  "test@example.com",
+		Orders: false,
+		Logins: true,
+	})
+	err = service.Deliver(ctx, &Event{Kind: EventOrderInvalid, Username: "test", Data: map[string]interface{}{"number": "1"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(sink.messages))
+
+	err = service.Deliver(ctx, &Event{Kind: EventNewDevice, Username: "test", Data: map[string]interface{}{"device": "curl"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(sink.messages))
+	assert.Equal(t, "test@example.com", sink.messages[1].To)
+
+	err = service.Deliver(ctx, &Event{Kind: "unknown", Username: "test"})
+	assert.NoError(t, err)
+}
+
+func TestNotifyFlushesOnClose(t *testing.T) {
+	cursor := &db.Cursor{DBInterface: mocks.NewMock()}
+	sink := &recordingSink{}
+	service := NewService(cursor, sink)
+
+	for i := 0; i < 3; i++ {
+		service.Notify(&Event{Kind: EventWithdrawal, Username: "test", Data: map[string]interface{}{"order": "1", "sum": 10}})
+	}
+	assert.NoError(t, service.Close(context.Background()))
+	assert.Len(t, sink.messages, 3)
+
+	service.Notify(&Event{Kind: EventWithdrawal, Username: "test", Data: map[string]interface{}{"order": "2", "sum": 10}})
+	assert.NoError(t, service.Close(context.Background()))
+	assert.Len(t, sink.messages, 3)
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notifications.log")
+	sink := NewFileSink(path)
+	assert.NoError(t, sink.Send(&Message{To: "test", Subject: "hello", Body: "world"}))
+	assert.NoError(t, sink.Send(&Message{To: "test", Subject: "second", Body: "message"}))
+
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(string(content), "Subject: hello"))
+	assert.True(t, strings.Contains(string(content), "Subject: second"))
+}
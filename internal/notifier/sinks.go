@@ -0,0 +1,39 @@
+package notifier
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/nmramorov/gophemart/internal/logger"
+)
+
+// LogSink writes messages to the info log, useful for development.
+type LogSink struct{}
+
+func (LogSink) Send(message *Message) error {
+	logger.InfoLog.Printf("Notification to %s: %s: %s", message.To, message.Subject, message.Body)
+	return nil
+}
+
+// FileSink appends messages to a file.
+type FileSink struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func NewFileSink(path string) *FileSink {
+	return &FileSink{Path: path}
+}
+
+func (f *FileSink) Send(message *Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.OpenFile(f.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = fmt.Fprintf(file, "To: %s\nSubject: %s\n\n%s\n", message.To, message.Subject, message.Body)
+	return err
+}
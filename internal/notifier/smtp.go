@@ -0,0 +1,42 @@
+package notifier
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+// SMTPSink sends messages through an SMTP server. Messages without an email
+// address are skipped.
+type SMTPSink struct {
+	Address  string
+	From     string
+	Username string
+	Password string
+}
+
+func NewSMTPSink(address string, from string, username string, password string) *SMTPSink {
+	return &SMTPSink{
+		Address:  address,
+		From:     from,
+		Username: username,
+		Password: password,
+	}
+}
+
+func (s *SMTPSink) Send(message *Message) error {
+	if !strings.Contains(message.To, "@") {
+		return nil
+	}
+	var auth smtp.Auth
+	if s.Username != "" {
+		host, _, err := net.SplitHostPort(s.Address)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+	body := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s", s.From, message.To, message.Subject, message.Body)
+	return smtp.SendMail(s.Address, auth, s.From, []string{message.To}, []byte(body))
+}
@@ -0,0 +1,76 @@
+package notifier
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// serveSMTP is a minimal SMTP stand-in which accepts a single message and
+// sends its DATA section to received.
+func serveSMTP(listener net.Listener, received chan<- string) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) {
+		conn.Write([]byte(line + "\r\n"))
+	}
+	reply("220 localhost ESMTP")
+	var data strings.Builder
+	inData := false
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		if inData {
+			if line == ".\r\n" {
+				inData = false
+				received <- data.String()
+				reply("250 OK")
+				continue
+			}
+			data.WriteString(line)
+			continue
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "DATA"):
+			inData = true
+			reply("354 End data with <CR><LF>.<CR><LF>")
+		case strings.HasPrefix(command, "QUIT"):
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestSMTPSink(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	received := make(chan string, 1)
+	go serveSMTP(listener, received)
+
+	sink := NewSMTPSink(listener.Addr().String(), "gophermart@localhost", "", "")
+	assert.NoError(t, sink.Send(&Message{To: "nobody", Subject: "skipped", Body: "skipped"}))
+	err = sink.Send(&Message{To: "test@example.com", Subject: "Order processed", Body: "Hello"})
+	assert.NoError(t, err)
+
+	data := <-received
+	assert.Contains(t, data, "To: test@example.com")
+	assert.Contains(t, data, "Subject: Order processed")
+	assert.Contains(t, data, "Hello")
+}
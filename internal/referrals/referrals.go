@@ -0,0 +1,98 @@
+package referrals
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nmramorov/gophemart/internal/db"
+	"github.com/nmramorov/gophemart/internal/errors"
+	"github.com/nmramorov/gophemart/internal/logger"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+const (
+	StatusPending  = "PENDING"
+	StatusRewarded = "REWARDED"
+	StatusRejected = "REJECTED"
+)
+
+// RewardStore is what paying out a referral needs, the referral and the
+// balances of both sides.
+type RewardStore interface {
+	db.ReferralRepository
+	db.BalanceRepository
+}
+
+func NewCode() string {
+	return strings.ToUpper(strings.ReplaceAll(uuid.NewString(), "-", "")[:10])
+}
+
+// ResolveCode finds the referrer owning code. An unknown code is a
+// validation error.
+func ResolveCode(ctx context.Context, repos db.ReferralRepository, code string) (string, error) {
+	referrer, err := repos.GetReferrerByCode(ctx, code)
+	if err != nil {
+		return "", err
+	}
+	if referrer == "" {
+		return "", errors.ErrValidation
+	}
+	return referrer, nil
+}
+
+// Register records that referee signed up with the code of referrer.
+// Self-referrals are refused and referrals over limit are kept as
+// REJECTED so they never pay out. Run it in a unit of work: the referrer is
+// locked before its referrals are counted, so concurrent registrations can
+// not exceed limit.
+func Register(ctx context.Context, repos db.ReferralRepository, referrer string, referee string, limit int, now time.Time) (*models.Referral, error) {
+	if referrer == referee {
+		return nil, errors.ErrValidation
+	}
+	referral := &models.Referral{
+		Referrer:  referrer,
+		Referee:   referee,
+		Status:    StatusPending,
+		CreatedAt: now,
+	}
+	if err := repos.LockReferrer(ctx, referrer); err != nil {
+		return nil, err
+	}
+	count, err := repos.CountReferrals(ctx, referrer)
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && count >= limit {
+		logger.InfoLog.Printf("Referrer %s reached the cap of %d referrals", referrer, limit)
+		referral.Status = StatusRejected
+	}
+	if err := repos.SaveReferral(ctx, referral); err != nil {
+		return nil, err
+	}
+	return referral, nil
+}
+
+// Reward pays bonus to both sides of a pending referral once the referee's
+// first order is PROCESSED. The guarded update makes it pay at most once.
+// Run it in the unit of work crediting the order, so the referral is only
+// marked rewarded together with both credits.
+func Reward(ctx context.Context, repos RewardStore, referee string, bonus float64, now time.Time) error {
+	referral, err := repos.GetReferral(ctx, referee)
+	if err != nil || referral == nil || referral.Status != StatusPending {
+		return err
+	}
+	completed, err := repos.CompleteReferral(ctx, referee, now)
+	if err != nil || !completed {
+		return err
+	}
+	for _, username := range []string{referral.Referrer, referral.Referee} {
+		if err := repos.CreditBalance(ctx, username, bonus); err != nil {
+			return err
+		}
+	}
+	logger.InfoLog.Printf("Referral of %s by %s rewarded with %f points", referee, referral.Referrer, bonus)
+	return nil
+}
@@ -0,0 +1,121 @@
+package referrals
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nmramorov/gophemart/internal/db"
+	"github.com/nmramorov/gophemart/internal/errors"
+	"github.com/nmramorov/gophemart/internal/mocks"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+func TestRegister(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	cursor := &db.Cursor{DBInterface: mocks.NewMock()}
+
+	_, err := Register(ctx, cursor, "alice", "alice", 2, now)
+	assert.Error(t, err)
+
+	referral, err := Register(ctx, cursor, "alice", "bob", 1, now)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusPending, referral.Status)
+
+	referral, err = Register(ctx, cursor, "alice", "carol", 1, now)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusRejected, referral.Status)
+}
+
+func TestReward(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	cursor := &db.Cursor{DBInterface: mocks.NewMock()}
+	cursor.SaveUserBalance(ctx, "alice", &models.Balance{User: "alice"})
+	cursor.SaveUserBalance(ctx, "bob", &models.Balance{User: "bob", Current: 10})
+	Register(ctx, cursor, "alice", "bob", 0, now)
+
+	assert.NoError(t, Reward(ctx, cursor, "bob", 50, now))
+	assert.NoError(t, Reward(ctx, cursor, "bob", 50, now))
+
+	alice, _ := cursor.GetUserBalance(ctx, "alice")
+	bob, _ := cursor.GetUserBalance(ctx, "bob")
+	assert.Equal(t, float64(50), alice.Current)
+	assert.Equal(t, float64(60), bob.Current)
+
+	referral, _ := cursor.GetReferral(ctx, "bob")
+	assert.Equal(t, StatusRewarded, referral.Status)
+}
+
+// failingCredit fails crediting user, standing in for a database error in
+// the middle of Reward.
+type failingCredit struct {
+	db.Repos
+	user string
+}
+
+func (f failingCredit) CreditBalance(ctx context.Context, username string, amount float64) error {
+	if username == f.user {
+		return errors.ErrValidation
+	}
+	return f.Repos.CreditBalance(ctx, username, amount)
+}
+
+func TestRewardRollsBack(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	cursor := &db.Cursor{DBInterface: mocks.NewMock()}
+	cursor.SaveUserBalance(ctx, "alice", &models.Balance{User: "alice"})
+	Register(ctx, cursor, "alice", "bob", 0, now)
+
+	err := cursor.WithTx(ctx, func(tx db.Repos) error {
+		return Reward(ctx, failingCredit{Repos: tx, user: "bob"}, "bob", 50, now)
+	})
+	assert.Error(t, err)
+
+	alice, _ := cursor.GetUserBalance(ctx, "alice")
+	assert.Equal(t, float64(0), alice.Current)
+	referral, _ := cursor.GetReferral(ctx, "bob")
+	assert.Equal(t, StatusPending, referral.Status)
+
+	err = cursor.WithTx(ctx, func(tx db.Repos) error {
+		return Reward(ctx, tx, "bob", 50, now)
+	})
+	assert.NoError(t, err)
+
+	alice, _ = cursor.GetUserBalance(ctx, "alice")
+	bob, _ := cursor.GetUserBalance(ctx, "bob")
+	assert.Equal(t, float64(50), alice.Current)
+	assert.Equal(t, float64(50), bob.Current)
+	referral, _ = cursor.GetReferral(ctx, "bob")
+	assert.Equal(t, StatusRewarded, referral.Status)
+}
+
+func TestConcurrentRegistrations(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	cursor := &db.Cursor{DBInterface: mocks.NewMock()}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(referee string) {
+			defer wg.Done()
+			assert.NoError(t, cursor.WithTx(ctx, func(tx db.Repos) error {
+				_, err := Register(ctx, tx, "alice", referee, 3, now)
+				return err
+			}))
+		}(fmt.Sprintf("user%d", i))
+	}
+	wg.Wait()
+
+	count, _ := cursor.CountReferrals(ctx, "alice")
+	assert.Equal(t, 3, count)
+	referrals, _ := cursor.GetReferrals(ctx, "alice")
+	assert.Len(t, referrals, 10)
+}
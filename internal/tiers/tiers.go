@@ -0,0 +1,60 @@
+package tiers
+
+import (
+	"context"
+	"time"
+
+	"github.com/nmramorov/gophemart/internal/db"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+// ROLLINGMONTHS is the window of accruals a tier is computed from.
+const ROLLINGMONTHS = 12
+
+// Store is what computing a tier needs, the tiers and the accruals.
+type Store interface {
+	db.TierRepository
+	db.LotRepository
+}
+
+// Resolve picks the highest tier reached by accrued and the one after it.
+// Tiers are expected to be sorted by MinAccrual.
+func Resolve(tiers []*models.Tier, accrued float64) (*models.Tier, *models.Tier) {
+	var current, next *models.Tier
+	for _, tier := range tiers {
+		if accrued >= tier.MinAccrual {
+			current = tier
+			continue
+		}
+		next = tier
+		break
+	}
+	return current, next
+}
+
+// ForUser computes the tier of the user from the accruals of the last
+// ROLLINGMONTHS months.
+func ForUser(ctx context.Context, repos Store, username string, now time.Time) (*models.TierStatus, error) {
+	tiers, err := repos.GetTiers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	accrued, err := repos.GetAccruedSince(ctx, username, now.AddDate(0, -ROLLINGMONTHS, 0))
+	if err != nil {
+		return nil, err
+	}
+	status := &models.TierStatus{
+		Multiplier: 1,
+		Accrued:    accrued,
+	}
+	current, next := Resolve(tiers, accrued)
+	if current != nil {
+		status.Tier = current.Name
+		status.Multiplier = current.Multiplier
+	}
+	if next != nil {
+		status.NextTier = next.Name
+		status.ToNextTier = next.MinAccrual - accrued
+	}
+	return status, nil
+}
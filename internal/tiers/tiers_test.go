@@ -0,0 +1,61 @@
+package tiers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/nmramorov/gophemart/internal/db"
+	"github.com/nmramorov/gophemart/internal/mocks"
+	"github.com/nmramorov/gophemart/internal/models"
+)
+
+func TestResolve(t *testing.T) {
+	tiers := []*models.Tier{
+		{Name: "bronze", MinAccrual: 0, Multiplier: 1},
+		{Name: "silver", MinAccrual: 1000, Multiplier: 1.25},
+		{Name: "gold", MinAccrual: 5000, Multiplier: 1.5},
+	}
+	tests := []struct {
+		name    string
+		accrued float64
+		current string
+		next    string
+	}{
+		{name: "Test bronze", accrued: 10, current: "bronze", next: "silver"},
+		{name: "Test silver boundary", accrued: 1000, current: "silver", next: "gold"},
+		{name: "Test gold", accrued: 7000, current: "gold"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current, next := Resolve(tiers, tt.accrued)
+			assert.Equal(t, tt.current, current.Name)
+			if tt.next == "" {
+				assert.Nil(t, next)
+				return
+			}
+			assert.Equal(t, tt.next, next.Name)
+		})
+	}
+}
+
+func TestForUser(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	cursor := &db.Cursor{DBInterface: mocks.NewMock()}
+	cursor.SaveAccrualLot(ctx, &models.AccrualLot{User: "test", Order: "1", Amount: 800, Base: 800, AccruedAt: now.AddDate(0, -1, 0)})
+	cursor.SaveAccrualLot(ctx, &models.AccrualLot{User: "test", Order: "2", Amount: 400, Base: 400, AccruedAt: now.AddDate(0, -2, 0)})
+	cursor.SaveAccrualLot(ctx, &models.AccrualLot{User: "test", Order: "3", Amount: 9000, Base: 9000, AccruedAt: now.AddDate(-2, 0, 0)})
+
+	status, err := ForUser(ctx, cursor, "test", now)
+	assert.NoError(t, err)
+	assert.Equal(t, &models.TierStatus{
+		Tier:       "silver",
+		Multiplier: 1.25,
+		Accrued:    1200,
+		NextTier:   "gold",
+		ToNextTier: 3800,
+	}, status)
+}
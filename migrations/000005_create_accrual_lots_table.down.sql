@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS expirations;
+DROP TABLE IF EXISTS accrual_lots;
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS accrual_lots (
+    id BIGSERIAL PRIMARY KEY,
+    username VARCHAR(50) NOT NULL,
+    _order VARCHAR(50) NOT NULL UNIQUE,
+    amount FLOAT NOT NULL DEFAULT 0.0,
+    remaining FLOAT NOT NULL DEFAULT 0.0,
+    accrued_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS accrual_lots_username_idx ON accrual_lots (username, accrued_at);
+
+CREATE TABLE IF NOT EXISTS expirations (
+    username VARCHAR(50) NOT NULL,
+    _order VARCHAR(50) NOT NULL,
+    _sum FLOAT NOT NULL DEFAULT 0.0,
+    expired_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS tiers (
+    _name VARCHAR(50) UNIQUE NOT NULL,
+    min_accrual FLOAT NOT NULL DEFAULT 0.0,
+    multiplier FLOAT NOT NULL DEFAULT 1.0
+);
+
+INSERT INTO tiers VALUES ('bronze', 0, 1.0), ('silver', 1000, 1.25), ('gold', 5000, 1.5)
+ON CONFLICT (_name) DO NOTHING;
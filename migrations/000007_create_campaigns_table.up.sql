@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS campaigns (
+    id BIGSERIAL PRIMARY KEY,
+    _name VARCHAR(100) NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    _value FLOAT NOT NULL DEFAULT 0.0,
+    first_order_only BOOLEAN NOT NULL DEFAULT FALSE,
+    per_user_cap INTEGER NOT NULL DEFAULT 0,
+    starts_at TIMESTAMP NOT NULL,
+    ends_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS campaign_bonuses (
+    username VARCHAR(50) NOT NULL,
+    _order VARCHAR(50) NOT NULL,
+    campaign_id BIGINT NOT NULL REFERENCES campaigns (id) ON DELETE CASCADE,
+    amount FLOAT NOT NULL DEFAULT 0.0,
+    credited_at TIMESTAMP NOT NULL,
+    UNIQUE (_order, campaign_id)
+);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS referral_codes (
+    username VARCHAR(50) UNIQUE NOT NULL,
+    code VARCHAR(50) UNIQUE NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS referrals (
+    referrer VARCHAR(50) NOT NULL,
+    referee VARCHAR(50) UNIQUE NOT NULL,
+    _status VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    rewarded_at TIMESTAMP
+);
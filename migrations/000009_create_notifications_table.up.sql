@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    username VARCHAR(50) UNIQUE NOT NULL,
+    email VARCHAR(200) NOT NULL DEFAULT '',
+    orders BOOLEAN NOT NULL DEFAULT TRUE,
+    withdrawals BOOLEAN NOT NULL DEFAULT TRUE,
+    logins BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+CREATE TABLE IF NOT EXISTS devices (
+    username VARCHAR(50) NOT NULL,
+    fingerprint VARCHAR(100) NOT NULL,
+    seen_at TIMESTAMP NOT NULL,
+    UNIQUE (username, fingerprint)
+);
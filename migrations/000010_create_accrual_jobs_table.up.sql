@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS accrual_jobs (
+    _order VARCHAR(50) PRIMARY KEY,
+    username VARCHAR(50) NOT NULL,
+    _status VARCHAR(20) NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_run_at TIMESTAMP NOT NULL,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS accrual_jobs_due_idx ON accrual_jobs (_status, next_run_at);
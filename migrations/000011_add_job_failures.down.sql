@@ -0,0 +1 @@
+ALTER TABLE accrual_jobs DROP COLUMN IF EXISTS failures;
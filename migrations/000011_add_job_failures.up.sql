@@ -0,0 +1,3 @@
+ALTER TYPE STATUS ADD VALUE IF NOT EXISTS 'FAILED';
+
+ALTER TABLE accrual_jobs ADD COLUMN IF NOT EXISTS failures INTEGER NOT NULL DEFAULT 0;
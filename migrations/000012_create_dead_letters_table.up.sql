@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS accrual_job_attempts (
+    id BIGSERIAL PRIMARY KEY,
+    _order VARCHAR(50) NOT NULL,
+    attempt INTEGER NOT NULL,
+    status_code INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    response_body TEXT NOT NULL DEFAULT '',
+    attempted_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS accrual_job_attempts_order_idx ON accrual_job_attempts (_order, attempted_at);
+
+CREATE TABLE IF NOT EXISTS dead_letters (
+    _order VARCHAR(50) PRIMARY KEY,
+    username VARCHAR(50) NOT NULL,
+    last_error TEXT NOT NULL DEFAULT '',
+    status_code INTEGER NOT NULL DEFAULT 0,
+    response_body TEXT NOT NULL DEFAULT '',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    failures INTEGER NOT NULL DEFAULT 0,
+    failed_at TIMESTAMP NOT NULL
+);
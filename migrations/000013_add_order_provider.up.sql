@@ -0,0 +1 @@
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS provider VARCHAR(50) NOT NULL DEFAULT '';
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS accrual_jobs_lease_idx;
+
+ALTER TABLE accrual_jobs DROP COLUMN IF EXISTS lease_until;
+ALTER TABLE accrual_jobs DROP COLUMN IF EXISTS owner;
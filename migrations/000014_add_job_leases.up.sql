@@ -0,0 +1,4 @@
+ALTER TABLE accrual_jobs ADD COLUMN IF NOT EXISTS owner VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE accrual_jobs ADD COLUMN IF NOT EXISTS lease_until TIMESTAMP NOT NULL DEFAULT 'epoch';
+
+CREATE INDEX IF NOT EXISTS accrual_jobs_lease_idx ON accrual_jobs (_status, lease_until);
@@ -0,0 +1,2 @@
+ALTER TABLE accrual_jobs DROP COLUMN IF EXISTS last_checked_at;
+ALTER TABLE accrual_jobs DROP COLUMN IF EXISTS last_status_code;
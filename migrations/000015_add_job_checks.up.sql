@@ -0,0 +1,2 @@
+ALTER TABLE accrual_jobs ADD COLUMN IF NOT EXISTS last_status_code INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE accrual_jobs ADD COLUMN IF NOT EXISTS last_checked_at TIMESTAMP;
@@ -0,0 +1,2 @@
+ALTER TABLE campaign_bonuses DROP CONSTRAINT IF EXISTS campaign_bonuses_seq_key;
+ALTER TABLE campaign_bonuses DROP COLUMN IF EXISTS seq;
@@ -0,0 +1,7 @@
+ALTER TABLE campaign_bonuses ADD COLUMN IF NOT EXISTS seq INTEGER;
+UPDATE campaign_bonuses SET seq = numbered.seq FROM (
+    SELECT _order, campaign_id, ROW_NUMBER() OVER (PARTITION BY username, campaign_id ORDER BY credited_at, _order) AS seq
+    FROM campaign_bonuses
+) AS numbered
+WHERE campaign_bonuses._order = numbered._order AND campaign_bonuses.campaign_id = numbered.campaign_id;
+ALTER TABLE campaign_bonuses ADD CONSTRAINT campaign_bonuses_seq_key UNIQUE (username, campaign_id, seq);
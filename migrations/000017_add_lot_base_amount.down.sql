@@ -0,0 +1 @@
+ALTER TABLE accrual_lots DROP COLUMN IF EXISTS base_amount;
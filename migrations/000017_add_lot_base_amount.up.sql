@@ -0,0 +1,4 @@
+ALTER TABLE accrual_lots ADD COLUMN IF NOT EXISTS base_amount FLOAT;
+UPDATE accrual_lots SET base_amount = amount WHERE base_amount IS NULL;
+ALTER TABLE accrual_lots ALTER COLUMN base_amount SET DEFAULT 0.0;
+ALTER TABLE accrual_lots ALTER COLUMN base_amount SET NOT NULL;
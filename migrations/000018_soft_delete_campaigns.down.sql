@@ -0,0 +1,5 @@
+ALTER TABLE campaign_bonuses DROP CONSTRAINT IF EXISTS campaign_bonuses_campaign_id_fkey;
+ALTER TABLE campaign_bonuses ADD CONSTRAINT campaign_bonuses_campaign_id_fkey
+    FOREIGN KEY (campaign_id) REFERENCES campaigns (id) ON DELETE CASCADE;
+DELETE FROM campaigns WHERE deleted_at IS NOT NULL;
+ALTER TABLE campaigns DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,4 @@
+ALTER TABLE campaigns ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE campaign_bonuses DROP CONSTRAINT IF EXISTS campaign_bonuses_campaign_id_fkey;
+ALTER TABLE campaign_bonuses ADD CONSTRAINT campaign_bonuses_campaign_id_fkey
+    FOREIGN KEY (campaign_id) REFERENCES campaigns (id) ON DELETE RESTRICT;